@@ -19,18 +19,36 @@ package main
 import (
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/pwittrock/continuous-apply/pkg/audit"
 	"github.com/pwittrock/continuous-apply/pkg/git"
 	"github.com/pwittrock/continuous-apply/pkg/poller"
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
 	"github.com/spf13/cobra"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 )
 
 // applyIssuesCmd represents the applyIssues command
 var (
-	p              = poller.Poller{}
-	applyIssuesCmd = &cobra.Command{
+	p                  = poller.Poller{}
+	targetRolloutTypes []string
+	targetPauses       []string
+	targetWindows      []string
+	targetNamespaces   []string
+	targetSelectors    []string
+	targetExcludeKinds []string
+	targetRenderers    []string
+	targetChecksums    []string
+	prunePropagation   []string
+	pruneGracePeriods  []string
+	disallowSecrets    []string
+	pauseAfter         []string
+	statusPlugins      []string
+	auditLog           string
+	applyIssuesCmd     = &cobra.Command{
 		Use:     "apply-issues",
 		Short:   "",
 		Long:    ``,
@@ -50,14 +68,163 @@ func validate(cmd *cobra.Command, args []string) error {
 	if p.Repo == "" {
 		return fmt.Errorf("--repo cannot be empty")
 	}
+
+	p.TargetRolloutTypes = map[string]string{}
+	for _, t := range targetRolloutTypes {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--target-rollout-types must be formatted as path=type, got %s", t)
+		}
+		p.TargetRolloutTypes[parts[0]] = parts[1]
+	}
+
+	p.TargetPauses = map[string]time.Duration{}
+	for _, t := range targetPauses {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--target-pauses must be formatted as path=duration, got %s", t)
+		}
+		d, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return fmt.Errorf("could not parse --target-pauses duration %s: %v", parts[1], err)
+		}
+		p.TargetPauses[parts[0]] = d
+	}
+
+	p.TargetWindows = map[string]string{}
+	for _, t := range targetWindows {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--target-windows must be formatted as path=HH:MM-HH:MM [timezone], got %s", t)
+		}
+		p.TargetWindows[parts[0]] = parts[1]
+	}
+
+	p.TargetNamespaces = map[string]string{}
+	for _, t := range targetNamespaces {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--target-namespaces must be formatted as path=namespace, got %s", t)
+		}
+		p.TargetNamespaces[parts[0]] = parts[1]
+	}
+
+	p.TargetSelectors = map[string]string{}
+	for _, t := range targetSelectors {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--target-selectors must be formatted as path=selector, got %s", t)
+		}
+		if existing := p.TargetSelectors[parts[0]]; existing != "" {
+			p.TargetSelectors[parts[0]] = existing + "," + parts[1]
+			continue
+		}
+		p.TargetSelectors[parts[0]] = parts[1]
+	}
+
+	p.TargetExcludeKinds = map[string][]string{}
+	for _, t := range targetExcludeKinds {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--target-exclude-kinds must be formatted as path=kind, got %s", t)
+		}
+		p.TargetExcludeKinds[parts[0]] = append(p.TargetExcludeKinds[parts[0]], parts[1])
+	}
+
+	p.TargetRenderers = map[string]string{}
+	for _, t := range targetRenderers {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--target-renderers must be formatted as path=renderer, got %s", t)
+		}
+		p.TargetRenderers[parts[0]] = parts[1]
+	}
+
+	p.TargetChecksums = map[string]string{}
+	for _, t := range targetChecksums {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--target-checksums must be formatted as path=sha256, got %s", t)
+		}
+		p.TargetChecksums[parts[0]] = parts[1]
+	}
+
+	p.PrunePropagation = map[string]string{}
+	for _, t := range prunePropagation {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--prune-propagation must be formatted as kind=policy, got %s", t)
+		}
+		if parts[1] != "Foreground" && parts[1] != "Background" && parts[1] != "Orphan" {
+			return fmt.Errorf("--prune-propagation policy must be Foreground, Background or Orphan, got %s", parts[1])
+		}
+		p.PrunePropagation[parts[0]] = parts[1]
+	}
+
+	p.PruneGracePeriods = map[string]int64{}
+	for _, t := range pruneGracePeriods {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--prune-grace-periods must be formatted as kind=seconds, got %s", t)
+		}
+		g, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse --prune-grace-periods seconds %s: %v", parts[1], err)
+		}
+		p.PruneGracePeriods[parts[0]] = g
+	}
+
+	for _, t := range statusPlugins {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--status-plugins must be formatted as group/Kind=command, got %s", t)
+		}
+		gk := strings.SplitN(parts[0], "/", 2)
+		if len(gk) != 2 {
+			return fmt.Errorf("--status-plugins must be keyed by group/Kind, got %s", parts[0])
+		}
+		rollout.RegisterStatusPlugin(gk[0], gk[1], parts[1])
+	}
+
+	if p.ScanBlockSeverity != "" && p.ScanBlockSeverity != "warning" && p.ScanBlockSeverity != "critical" {
+		return fmt.Errorf("--scan-block-severity must be warning or critical, got %s", p.ScanBlockSeverity)
+	}
+
+	if p.SyncStrategy != "" && p.SyncStrategy != "force" {
+		return fmt.Errorf("--git-sync-strategy must be force or empty, got %s", p.SyncStrategy)
+	}
+
+	if p.PreemptionPolicy != "" && p.PreemptionPolicy != "preempt" {
+		return fmt.Errorf("--preemption-policy must be preempt or empty, got %s", p.PreemptionPolicy)
+	}
+
+	if p.DryRun != "" && p.DryRun != "client" && p.DryRun != "server" {
+		return fmt.Errorf("--dry-run must be client or server, got %s", p.DryRun)
+	}
+
+	p.DisallowPlaintextSecrets = map[string]bool{}
+	for _, t := range disallowSecrets {
+		p.DisallowPlaintextSecrets[t] = true
+	}
+
+	p.TargetPauseAfter = map[string]bool{}
+	for _, t := range pauseAfter {
+		p.TargetPauseAfter[t] = true
+	}
 	return nil
 }
 
 func run(cmd *cobra.Command, args []string) {
 	var err error
+	if auditLog != "" {
+		p.Audit = audit.NewLogger(auditLog)
+	}
 	if p.GitClient, err = git.NewManager(p.Owner, p.Repo, ""); err != nil {
 		log.Fatal(err)
 	}
+	if err = p.ConfigureGitClient(p.GitClient); err != nil {
+		log.Fatal(err)
+	}
 	if err = p.Run(); err != nil {
 		log.Fatal(err)
 	}
@@ -83,14 +250,118 @@ func init() {
 	applyIssuesCmd.Flags().StringSliceVar(&p.ApplyTargets, "apply-targets",
 		[]string{"./"}, "")
 
-	applyIssuesCmd.Flags().StringVar(&p.Type, "sync-type", "issue", "issue || pr")
-	applyIssuesCmd.Flags().StringVar(&p.RolloutType, "rollout-type", "sequential", "sequential || parallel")
+	applyIssuesCmd.Flags().StringVar(&p.Type, "sync-type", "issue",
+		"issue || pr.  pr posts the rollout status comment directly on the merged PR "+
+			"and runs before/after actions against it, skipping rollout issues entirely.")
+	applyIssuesCmd.Flags().StringVar(&p.RolloutType, "rollout-type", "sequential", "sequential || parallel || blue-green")
+	applyIssuesCmd.Flags().StringSliceVar(&targetRolloutTypes, "target-rollout-types", []string{},
+		"Per-target rollout type overrides formatted as path=type.")
+	applyIssuesCmd.Flags().StringSliceVar(&targetPauses, "target-pauses", []string{},
+		"Per-target pause overrides formatted as path=duration.")
+	applyIssuesCmd.Flags().StringSliceVar(&targetWindows, "target-windows", []string{},
+		"Per-target rollout windows formatted as path=HH:MM-HH:MM with an optional IANA "+
+			"timezone, e.g. migrations=02:00-04:00 America/New_York.")
+	applyIssuesCmd.Flags().StringSliceVar(&targetNamespaces, "target-namespaces", []string{},
+		"Per-target namespace overrides formatted as path=namespace.")
+	applyIssuesCmd.Flags().StringSliceVar(&targetSelectors, "target-selectors", []string{},
+		"Per-target label selectors formatted as path=key=value.  Only rendered objects "+
+			"matching the selector are applied; repeat the flag to AND requirements.")
+	applyIssuesCmd.Flags().StringSliceVar(&targetExcludeKinds, "target-exclude-kinds", []string{},
+		"Per-target kind exclusions formatted as path=Kind or path=group/Kind.  Repeat the "+
+			"flag to exclude several kinds.")
+	applyIssuesCmd.Flags().StringSliceVar(&targetRenderers, "target-renderers", []string{},
+		"Per-target renderer overrides formatted as path=renderer.  Targets default to kustomize.")
+	applyIssuesCmd.Flags().StringVar(&p.RenderSandbox, "render-sandbox", "",
+		"Wrapper command renders are exec'd through - e.g. bwrap or nsjail flags - with "+
+			"credential material stripped from the environment.")
+	applyIssuesCmd.Flags().StringSliceVar(&targetChecksums, "target-checksums", []string{},
+		"Expected sha256 digests of each target's source files formatted as path=sha256, "+
+			"verified before rendering.")
+	applyIssuesCmd.Flags().StringVar(&p.VerifyCommand, "verify-command", "",
+		"Provenance verification command - e.g. cosign - run with each target path appended "+
+			"before rendering.  A non-zero exit fails the rollout.")
+	applyIssuesCmd.Flags().StringVar(&p.SmokeTestCommand, "smoke-test-command", "",
+		"Smoke test suite run with each target path appended after the target's objects "+
+			"roll out.  A non-zero exit fails the rollout.")
+	applyIssuesCmd.Flags().StringSliceVar(&disallowSecrets, "disallow-plaintext-secrets", []string{},
+		"Target paths that must not render Secrets with inline plaintext data.")
+	applyIssuesCmd.Flags().StringSliceVar(&pauseAfter, "pause-after", []string{},
+		"Target paths to pause after until a collaborator comments /continue on the "+
+			"rollout issue.")
+	applyIssuesCmd.Flags().StringSliceVar(&p.AllowedKinds, "allowed-kinds", []string{},
+		"Fail the rollout if a rendered object's kind is not in the list.  Empty allows all kinds.")
+	applyIssuesCmd.Flags().StringSliceVar(&p.DeniedKinds, "denied-kinds", []string{},
+		"Fail the rollout if a rendered object's kind is in the list.")
+	applyIssuesCmd.Flags().BoolVar(&p.ScanManifests, "scan-manifests", false,
+		"Run configuration checks on rendered objects and post the findings to the issue comment.")
+	applyIssuesCmd.Flags().StringVar(&p.ScanBlockSeverity, "scan-block-severity", "",
+		"Fail the rollout on findings of this severity or higher.  warning || critical.  "+
+			"Empty posts findings without blocking.")
+	applyIssuesCmd.Flags().BoolVar(&p.PreflightAdmission, "preflight-admission", false,
+		"Server-side dry-run each object before applying anything, surfacing admission "+
+			"refusals - e.g. Pod Security violations - up front.")
+	applyIssuesCmd.Flags().BoolVar(&p.CheckCapacity, "check-capacity", false,
+		"Compare the requests being added against allocatable capacity and namespace "+
+			"ResourceQuotas before applying, warning when the rollout would be unschedulable.")
+	applyIssuesCmd.Flags().BoolVar(&p.BlockOnCapacity, "block-on-capacity", false,
+		"Fail the rollout instead of warning when --check-capacity finds it unschedulable.")
+	applyIssuesCmd.Flags().BoolVar(&p.SkipUnchanged, "skip-unchanged", false,
+		"Skip re-applying objects whose live cluster state already matches the rendered manifest.")
+	applyIssuesCmd.Flags().BoolVar(&p.CheckDeterminism, "check-determinism", false,
+		"Render each target twice and flag targets whose output differs between renders.")
+	applyIssuesCmd.Flags().StringSliceVar(&statusPlugins, "status-plugins", []string{},
+		"Exec plugins reporting rollout status for kinds without a built-in viewer, formatted "+
+			"as group/Kind=command.  The plugin receives the live object JSON on stdin; exit "+
+			"code 0 means done, 10 means still progressing.")
+	applyIssuesCmd.Flags().BoolVar(&p.DetectNoop, "detect-noop", false,
+		"Skip the rollout wait loop when the apply reported every object unchanged and "+
+			"label the issue no-changes.")
+	applyIssuesCmd.Flags().IntVar(&p.MaxParallelTargets, "max-parallel-targets", 0,
+		"Limit how many parallel targets roll out at a time.  0 rolls out all at once.")
+	applyIssuesCmd.Flags().BoolVar(&p.CreateNamespaces, "create-namespaces", false,
+		"Create and label namespaces referenced by rendered objects that don't exist yet.")
+	applyIssuesCmd.Flags().BoolVar(&p.Prune, "prune", false,
+		"Delete objects stamped by earlier commits that are no longer rendered.")
+	applyIssuesCmd.Flags().BoolVar(&p.Embedded, "embedded", false,
+		"Apply and diff in-process through the API instead of execing kubectl, for images "+
+			"without external binaries.  Targets default to the raw renderer.")
+	applyIssuesCmd.Flags().StringSliceVar(&prunePropagation, "prune-propagation", []string{},
+		"Per-kind deletion propagation overrides formatted as kind=policy.  Kinds default to Background.")
+	applyIssuesCmd.Flags().StringSliceVar(&pruneGracePeriods, "prune-grace-periods", []string{},
+		"Per-kind deletion grace period overrides formatted as kind=seconds.")
 
+	applyIssuesCmd.Flags().BoolVar(&p.PrioritizeReverts, "prioritize-reverts", false,
+		"Label rollout issues for revert PRs with the revert label and roll them out ahead "+
+			"of other queued merges.")
+	applyIssuesCmd.Flags().BoolVar(&p.RevertsSkipGates, "reverts-skip-gates", false,
+		"Let revert PRs bypass the match filters so emergency reverts don't wait on approval labels.")
+	applyIssuesCmd.Flags().BoolVar(&p.Freeze, "freeze", false,
+		"Stop new rollouts from starting.  Queued rollouts are annotated and resume when "+
+			"the freeze lifts or an admin comments /apply force.")
+	applyIssuesCmd.Flags().StringVar(&p.FreezeLabel, "freeze-label", "",
+		"Freeze all rollouts while any open issue carries the label, e.g. deploy-freeze "+
+			"on a pinned issue.")
+	applyIssuesCmd.Flags().StringVar(&p.PriorityLabel, "priority-label", "",
+		"Label - e.g. priority/critical - that makes merged PRs jump the rollout queue.")
+	applyIssuesCmd.Flags().StringVar(&p.PreemptionPolicy, "preemption-policy", "",
+		"Whether a priority PR may preempt an in-flight rollout.  preempt interrupts the "+
+			"rollout; empty waits for it to finish.")
 	applyIssuesCmd.Flags().StringSliceVar(&p.MatchLabels, "match-labels", []string{},
 		"Only apply issues with these labels.")
 	applyIssuesCmd.Flags().StringVar(&p.MatchAssignee, "match-assignee", "", "")
 	applyIssuesCmd.Flags().StringVar(&p.MatchState, "match-state", "", "")
 	applyIssuesCmd.Flags().StringVar(&p.MatchMilestone, "match-milestone", "", "")
+	applyIssuesCmd.Flags().IntVar(&p.HeartbeatIssue, "heartbeat-issue", 0,
+		"Issue to post an idle heartbeat comment on when no merges match the filters.")
+	applyIssuesCmd.Flags().DurationVar(&p.HeartbeatInterval, "heartbeat-interval", 0,
+		"Minimum time between heartbeat comment updates.  Defaults to an hour.")
+	applyIssuesCmd.Flags().IntVar(&p.QueueIssue, "queue-issue", 0,
+		"Pinned issue to mirror the deploy queue onto.")
+	applyIssuesCmd.Flags().BoolVar(&p.NotifyPR, "notify-pr", false,
+		"Post a short outcome comment on the originating PR after each rollout.")
+	applyIssuesCmd.Flags().StringVar(&p.ApprovalTeam, "approval-team", "",
+		"GitHub team slug - e.g. platform-oncall - a member of which must have approved "+
+			"the merged PR before it is applied.")
 
 	applyIssuesCmd.Flags().StringSliceVar(&p.BeforeAddLabels, "before-add-labels", []string{},
 		"Labels to set before starting a rollout.")
@@ -101,6 +372,8 @@ func init() {
 	applyIssuesCmd.Flags().StringSliceVar(&p.BeforeRemoveAssignees, "before-remove-assignees", []string{},
 		"Assignees to remove after completing a rollout.")
 	applyIssuesCmd.Flags().StringVar(&p.BeforeSetState, "before-set-state", "", "")
+	applyIssuesCmd.Flags().StringVar(&p.BeforeSetMilestone, "before-set-milestone", "",
+		"Milestone to set on the issue or PR before starting a rollout.")
 
 	applyIssuesCmd.Flags().StringSliceVar(&p.AfterAddLabels, "after-add-labels", []string{},
 		"Labels to set before starting a rollout.")
@@ -111,6 +384,8 @@ func init() {
 	applyIssuesCmd.Flags().StringSliceVar(&p.AfterRemoveAssignees, "after-remove-assignees", []string{},
 		"Assignees to remove after completing a rollout.")
 	applyIssuesCmd.Flags().StringVar(&p.AfterSetState, "after-set-state", "", "")
+	applyIssuesCmd.Flags().StringVar(&p.AfterSetMilestone, "after-set-milestone", "",
+		"Milestone to set on the issue or PR after completing a rollout.")
 
 	applyIssuesCmd.Flags().StringSliceVar(&p.SkipAddLabels, "skip-add-labels", []string{}, "")
 	applyIssuesCmd.Flags().StringVar(&p.SkipSetState, "skip-set-state", "", "")
@@ -118,4 +393,73 @@ func init() {
 	applyIssuesCmd.Flags().DurationVar(&p.Pause, "pause", 1*time.Second,
 		"Pause time between checking rollout status")
 
+	applyIssuesCmd.Flags().BoolVar(&p.PublishSummary, "publish-summary", false,
+		"Publish a JSON summary of each rollout as a gist linked from the comment.")
+
+	applyIssuesCmd.Flags().StringVar(&p.ObjectURLTemplate, "object-url-template", "",
+		"Render each object's name in the comment as a dashboard link.  {{namespace}}, "+
+			"{{kind}}, and {{name}} are substituted per object.")
+
+	applyIssuesCmd.Flags().StringVar(&p.PrometheusURL, "prometheus-url", "",
+		"Base URL of the Prometheus queried for object readiness gate annotations.")
+
+	applyIssuesCmd.Flags().BoolVar(&p.ThreadComments, "thread-comments", false,
+		"Post a fresh status comment per rollout, minimizing the previous one, and react "+
+			"to the comment as the rollout starts and finishes.")
+
+	applyIssuesCmd.Flags().StringVar(&auditLog, "audit-log", "",
+		"File to append audit records of applies and GitHub mutations to.")
+
+	applyIssuesCmd.Flags().StringVar(&p.Kubeconfig, "kubeconfig", "",
+		"Path to a kubeconfig used for status checks and applies.  Defaults to the in-cluster config.")
+	applyIssuesCmd.Flags().StringVar(&p.Context, "context", "",
+		"Kubeconfig context used for status checks and applies.")
+	applyIssuesCmd.Flags().StringVar(&p.WorkDir, "workdir", "",
+		"Directory to clone the repo into.  Defaults to the current directory.")
+	applyIssuesCmd.Flags().BoolVar(&p.GitHubDryRun, "github-dry-run", false,
+		"Log GitHub writes instead of performing them.  Useful for local development.")
+	applyIssuesCmd.Flags().StringVar(&p.DryRun, "dry-run", "",
+		"Run the full pipeline without persisting objects to the cluster.  client || server")
+	applyIssuesCmd.Flags().StringVar(&p.CommitterName, "git-committer-name", "",
+		"Git committer name used for write operations.")
+	applyIssuesCmd.Flags().StringVar(&p.CommitterEmail, "git-committer-email", "",
+		"Git committer email used for write operations.")
+	applyIssuesCmd.Flags().StringVar(&p.SyncStrategy, "git-sync-strategy", "",
+		"How aggressively to clean the git work tree when syncing.  force discards modified "+
+			"tracked files, removes ignored files, and initializes submodules.")
+	applyIssuesCmd.Flags().BoolVar(&p.SparseCheckout, "sparse-checkout", false,
+		"Limit the checkout to the apply targets plus the kustomize bases they reference, "+
+			"reducing clone time and disk usage in monorepos.")
+	applyIssuesCmd.Flags().BoolVar(&p.Submodules, "git-submodules", false,
+		"Initialize and update git submodules when syncing, for repos vendoring bases via submodules.")
+	applyIssuesCmd.Flags().BoolVar(&p.LFS, "git-lfs", false,
+		"Pull git-lfs content when syncing, for repos storing large files via LFS.")
+	applyIssuesCmd.Flags().StringVar(&p.Proxy, "proxy", "",
+		"HTTP(S) proxy URL used for git and GitHub API traffic.")
+	applyIssuesCmd.Flags().StringVar(&p.CABundle, "ca-bundle", "",
+		"PEM file of additional CAs to trust for git and GitHub API traffic.")
+
+	applyIssuesCmd.Flags().IntVar(&p.MaxFailures, "max-failures", 0,
+		"Stop auto-applying a commit after this many consecutive failed rollouts.  0 retries forever.")
+	applyIssuesCmd.Flags().StringVar(&p.FailureLabel, "failure-label", "needs-attention",
+		"Label set on the issue when --max-failures is reached.")
+	applyIssuesCmd.Flags().StringVar(&p.NotifyCommand, "notify-command", "",
+		"Shell command run when --max-failures is reached.")
+
+	applyIssuesCmd.Flags().StringVar(&p.ProgressNamespace, "progress-namespace", "",
+		"Namespace of the applier Deployment to annotate with rollout progress.")
+	applyIssuesCmd.Flags().StringVar(&p.SnapshotNamespace, "snapshot-namespace", "",
+		"Namespace to store a gzipped ConfigMap of the fully rendered manifests per commit in.")
+	applyIssuesCmd.Flags().StringVar(&p.StatusBranch, "status-branch", "",
+		"Repo branch to push a structured per-applier status file to on each state change.")
+
+	applyIssuesCmd.Flags().DurationVar(&p.RequestInterval, "github-request-interval", 0,
+		"Minimum time between GitHub API requests.  Used to share one bot account across appliers.")
+	applyIssuesCmd.Flags().DurationVar(&p.RequestTimeout, "github-request-timeout", 0,
+		"Timeout for each GitHub API request.  Defaults to 30s.")
+	applyIssuesCmd.Flags().IntVar(&p.MaxRetries, "github-max-retries", 0,
+		"Number of times failed GitHub API reads are retried with backoff.")
+	applyIssuesCmd.Flags().StringVar(&p.APIEndpoint, "github-endpoint", "",
+		"Base URL for GitHub API requests.  Point at a ghproxy-style caching proxy to cut API usage.")
+
 }
@@ -0,0 +1,96 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	"github.com/spf13/cobra"
+)
+
+// genRbacCmd represents the genRbac command
+var (
+	rbacName             string
+	rbacServiceAccount   string
+	rbacServiceAccountNs string
+	rbacApplyTargets     []string
+	genRbacCmd           = &cobra.Command{
+		Use: "gen-rbac",
+		Short: "Emit a minimal Role/ClusterRole and binding for the applier ServiceAccount " +
+			"derived from the target manifests.",
+		Long:    ``,
+		Example: ``,
+		PreRunE: validateGenRbac,
+		Run:     runGenRbac,
+	}
+)
+
+func validateGenRbac(cmd *cobra.Command, args []string) error {
+	if rbacName == "" {
+		return fmt.Errorf("--name cannot be empty")
+	}
+	if rbacServiceAccount == "" {
+		return fmt.Errorf("--service-account cannot be empty")
+	}
+	return nil
+}
+
+func runGenRbac(cmd *cobra.Command, args []string) {
+	objects := []*rollout.Object{}
+	for _, path := range rbacApplyTargets {
+		out, err := exec.Command("kustomize", "build", path).CombinedOutput()
+		if err != nil {
+			log.Printf("failed to kustomize %s\n", out)
+			log.Fatal(err)
+		}
+		for _, o := range strings.Split(string(out), "---\n") {
+			obj, err := rollout.ParseObject([]byte(o))
+			if err != nil {
+				log.Fatal(err)
+			}
+			objects = append(objects, obj)
+		}
+	}
+
+	rbac, err := rollout.GenerateRBAC(rbacName, rbacServiceAccount, rbacServiceAccountNs, objects)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(rbac)
+}
+
+func init() {
+	rootCmd.AddCommand(genRbacCmd)
+
+	genRbacCmd.Flags().StringVar(&rbacName, "name", "",
+		"Name used for the generated Roles and bindings")
+	genRbacCmd.MarkFlagRequired("name")
+
+	genRbacCmd.Flags().StringVar(&rbacServiceAccount, "service-account", "",
+		"ServiceAccount the applier pod runs as")
+	genRbacCmd.MarkFlagRequired("service-account")
+
+	genRbacCmd.Flags().StringVar(&rbacServiceAccountNs, "service-account-namespace", "default",
+		"Namespace of the applier ServiceAccount")
+
+	genRbacCmd.Flags().StringSliceVar(&rbacApplyTargets, "apply-targets",
+		[]string{"./"}, "")
+}
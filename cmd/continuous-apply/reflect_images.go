@@ -0,0 +1,99 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"log"
+
+	"github.com/pwittrock/continuous-apply/pkg/reflector"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var rv *viper.Viper
+var imageReflector *reflector.Reflector
+
+// reflectImagesCmd represents the reflectImages command
+var (
+	reflectImagesCmd = &cobra.Command{
+		Use:     "reflect-images",
+		Short:   "",
+		Long:    ``,
+		Example: ``,
+		PreRunE: validateReflectImages,
+		Run:     runReflectImages,
+	}
+)
+
+func validateReflectImages(cmd *cobra.Command, args []string) error {
+	err := rv.ReadInConfig() // Find and read the config file
+	if err != nil {          // Handle errors reading the config file
+		return fmt.Errorf("could not read config file: %s \n", err)
+	}
+
+	values := &imageReflector.Spec
+	err = rv.Unmarshal(values)
+	if err != nil { // Handle errors reading the config file
+		return fmt.Errorf("could not parse config file: %s \n", err)
+	}
+
+	if values.Repo.Owner == "" {
+		return fmt.Errorf("must specify repo.owner as the owner of a git repo")
+	}
+
+	if values.Repo.Repo == "" {
+		return fmt.Errorf("must specify repo.repo as the name of a git repo")
+	}
+
+	if len(values.Images) == 0 {
+		return fmt.Errorf("must specify images to watch for new tags")
+	}
+
+	for _, i := range values.Images {
+		if i.Name == "" || i.TagPattern == "" || i.Path == "" {
+			return fmt.Errorf("each image must specify name, tagPattern and path")
+		}
+	}
+
+	imageReflector.AccessToken = rv.GetString("accesstoken")
+	if imageReflector.AccessToken == "" {
+		return fmt.Errorf("must specify CONTINUOUSAPPLY_ACCESSTOKEN with a GitHub access token")
+	}
+
+	return nil
+}
+
+func runReflectImages(cmd *cobra.Command, args []string) {
+	if err := imageReflector.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(reflectImagesCmd)
+	imageReflector = &reflector.Reflector{}
+
+	rv = viper.New()
+	rv.SetConfigName("config")
+	rv.AddConfigPath("/etc/continuous-apply/image-reflector")
+	rv.AddConfigPath("$HOME/.continuous-apply/image-reflector")
+
+	rv.SetEnvPrefix("continuousapply")
+	rv.BindEnv("accesstoken")
+}
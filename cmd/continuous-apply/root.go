@@ -21,11 +21,13 @@ import (
 	"os"
 
 	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pwittrock/continuous-apply/pkg/debug"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
+var debugAddress string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -40,6 +42,9 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		debug.Serve(debugAddress)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -58,6 +63,8 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.continuous-apply.yaml)")
+	rootCmd.PersistentFlags().StringVar(&debugAddress, "debug-address", "",
+		"Address to serve /debug/pprof and /debug/vars on, e.g. localhost:6060.  Empty disables the endpoints.")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -17,10 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"flag"
 	"log"
 
 	"github.com/pwittrock/continuous-apply/pkg/apis"
 	"github.com/pwittrock/continuous-apply/pkg/controller"
+	"github.com/pwittrock/continuous-apply/pkg/controller/continuousapply"
+	"github.com/pwittrock/continuous-apply/pkg/debug"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -28,6 +31,31 @@ import (
 )
 
 func main() {
+	debugAddress := flag.String("debug-address", "",
+		"Address to serve /debug/pprof and /debug/vars on, e.g. localhost:6060.  Empty disables the endpoints.")
+	watchNamespace := flag.String("watch-namespace", "",
+		"Namespace to reconcile ContinuousApply objects in.  Empty reconciles cluster-wide.")
+	namespaceDefaults := flag.String("namespace-defaults", "",
+		"YAML file of per-namespace default settings - image, pollInterval, allowedRepos, "+
+			"maxAppliers - keyed by namespace, for delegating CR creation to tenants.")
+	maxPerNamespace := flag.Int("max-appliers-per-namespace", 0,
+		"Cap on applier Deployments per namespace.  Excess ContinuousApply objects are "+
+			"queued with the reason recorded in status.  0 is unlimited.")
+	maxPerRepo := flag.Int("max-appliers-per-repo", 0,
+		"Cap on appliers polling the same owner/repo, protecting the GitHub API quota.  "+
+			"0 is unlimited.")
+	flag.Parse()
+	debug.Serve(*debugAddress)
+
+	continuousapply.WatchNamespace = *watchNamespace
+	continuousapply.MaxAppliersPerNamespace = *maxPerNamespace
+	continuousapply.MaxAppliersPerRepo = *maxPerRepo
+	if *namespaceDefaults != "" {
+		if err := continuousapply.LoadNamespaceDefaults(*namespaceDefaults); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// Get a config to talk to the apiserver
 	cfg, err := config.GetConfig()
 	if err != nil {
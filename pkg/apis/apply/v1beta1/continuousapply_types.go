@@ -26,7 +26,8 @@ type ContinuousApplySpec struct {
 	// Type may be "issue" or "pr".  Defaults to "pr".
 	Type string `json:"type"`
 
-	// RolloutType
+	// RolloutType may be "sequential", "parallel", or "blue-green".  May be overridden
+	// per-target.
 	RolloutType string `json:"rolloutType"`
 
 	// Repo is the Git repo to pull from.
@@ -49,12 +50,312 @@ type ContinuousApplySpec struct {
 
 	// Match filters which PRs or Issues to apply.
 	Match GitMatch `json:"match"`
+
+	// PublishSummary uploads a JSON summary of each rollout as a gist linked from the comment.
+	// +optional
+	PublishSummary bool `json:"publishSummary,omitempty"`
+
+	// AuditLog is a file the applier appends audit records of applies and GitHub mutations to.
+	// +optional
+	AuditLog string `json:"auditLog,omitempty"`
+
+	// ThreadComments posts a fresh status comment per rollout, minimizing the previous one,
+	// and reacts to the comment as the rollout starts and finishes.
+	// +optional
+	ThreadComments bool `json:"threadComments,omitempty"`
+
+	// ObjectURLTemplate renders each object's name in the comment as a link to a dashboard.
+	// {{namespace}}, {{kind}}, and {{name}} are substituted per object - e.g.
+	// https://console/workloads/{{namespace}}/{{kind}}/{{name}}.
+	// +optional
+	ObjectURLTemplate string `json:"objectURLTemplate,omitempty"`
+
+	// PrometheusURL is the base URL of the Prometheus queried for object readiness gates.
+	// +optional
+	PrometheusURL string `json:"prometheusURL,omitempty"`
+
+	// DryRun runs the full pipeline without persisting objects to the cluster.
+	// May be client or server.
+	// +optional
+	DryRun string `json:"dryRun,omitempty"`
+
+	// Git configures the git identity and network settings used by the applier.
+	// +optional
+	Git GitConfig `json:"git,omitempty"`
+
+	// Tools pins the kubectl and kustomize versions the applier runs with.  Pinned tools
+	// are downloaded for the node's architecture - amd64 or arm64 - by an init container
+	// and verified against their published digests, since tool-version skew vs the cluster
+	// causes apply behavior differences.
+	// +optional
+	Tools ToolVersions `json:"tools,omitempty"`
+
+	// MaxFailures stops auto-applying a commit after this many consecutive failed rollouts.
+	// Zero retries forever.
+	// +optional
+	MaxFailures int `json:"maxFailures,omitempty"`
+
+	// FailureLabel is the label set on the issue when MaxFailures is reached.
+	// Defaults to needs-attention.
+	// +optional
+	FailureLabel string `json:"failureLabel,omitempty"`
+
+	// NotifyCommand is a shell command the applier runs when MaxFailures is reached.
+	// +optional
+	NotifyCommand string `json:"notifyCommand,omitempty"`
+
+	// PrioritizeReverts labels rollout issues for revert PRs with the revert label and
+	// rolls them out ahead of other queued merges - reverts are the emergency brake.
+	// +optional
+	PrioritizeReverts bool `json:"prioritizeReverts,omitempty"`
+
+	// RevertsSkipGates lets revert PRs bypass the match filters so emergency reverts don't
+	// wait on approval labels.
+	// +optional
+	RevertsSkipGates bool `json:"revertsSkipGates,omitempty"`
+
+	// Freeze stops new rollouts from starting.  Queued rollouts are annotated and resume
+	// when the freeze lifts or an admin comments /apply force.
+	// +optional
+	Freeze bool `json:"freeze,omitempty"`
+
+	// FreezeLabel freezes all rollouts while any open issue carries the label - e.g.
+	// deploy-freeze on a pinned issue.
+	// +optional
+	FreezeLabel string `json:"freezeLabel,omitempty"`
+
+	// PriorityLabel makes merged PRs carrying the label - e.g. priority/critical - jump
+	// the rollout queue.
+	// +optional
+	PriorityLabel string `json:"priorityLabel,omitempty"`
+
+	// PreemptionPolicy controls whether a priority PR may preempt an in-flight rollout.
+	// preempt interrupts the rollout; empty waits for it to finish.
+	// +optional
+	PreemptionPolicy string `json:"preemptionPolicy,omitempty"`
+
+	// HeartbeatIssue is an issue to post an idle heartbeat comment on when no merges
+	// match the poller's filters, so admins can see why the applier is idle.
+	// +optional
+	HeartbeatIssue int `json:"heartbeatIssue,omitempty"`
+
+	// HeartbeatInterval is the minimum time between heartbeat comment updates, e.g. 1h.
+	// +optional
+	HeartbeatInterval string `json:"heartbeatInterval,omitempty"`
+
+	// QueueIssue is a pinned issue to mirror the deploy queue onto so developers can see
+	// where their change is.
+	// +optional
+	QueueIssue int `json:"queueIssue,omitempty"`
+
+	// NotifyPR posts a short outcome comment with a permalink to the rollout status on
+	// the originating PR after each rollout.
+	// +optional
+	NotifyPR bool `json:"notifyPR,omitempty"`
+
+	// ApprovalTeam is a GitHub team slug - e.g. platform-oncall - a member of which must
+	// have approved the merged PR before it is applied.
+	// +optional
+	ApprovalTeam string `json:"approvalTeam,omitempty"`
+
+	// AllowedKinds fails the rollout if a rendered object's kind is not in the list.
+	// Empty allows all kinds.
+	// +optional
+	AllowedKinds []string `json:"allowedKinds,omitempty"`
+
+	// DeniedKinds fails the rollout if a rendered object's kind is in the list - e.g.
+	// ClusterRoleBinding or CustomResourceDefinition for tenant appliers.
+	// +optional
+	DeniedKinds []string `json:"deniedKinds,omitempty"`
+
+	// ScanManifests runs configuration checks on rendered objects - privileged containers,
+	// host namespaces, hostPath volumes, missing resource limits - and posts the findings to
+	// the issue comment.
+	// +optional
+	ScanManifests bool `json:"scanManifests,omitempty"`
+
+	// ScanBlockSeverity fails the rollout on findings of this severity or higher - warning
+	// or critical.  Empty posts findings without blocking.
+	// +optional
+	ScanBlockSeverity string `json:"scanBlockSeverity,omitempty"`
+
+	// PreflightAdmission server-side dry-runs each object before applying anything, so
+	// admission refusals - e.g. Pod Security violations - surface up front instead of
+	// failing the rollout halfway through.
+	// +optional
+	PreflightAdmission bool `json:"preflightAdmission,omitempty"`
+
+	// CheckCapacity compares the aggregate cpu and memory requests being added against
+	// allocatable cluster capacity and namespace ResourceQuotas before applying, warning in
+	// the comment when the rollout would be unschedulable.
+	// +optional
+	CheckCapacity bool `json:"checkCapacity,omitempty"`
+
+	// BlockOnCapacity fails the rollout instead of warning when CheckCapacity finds the
+	// rollout would be unschedulable.
+	// +optional
+	BlockOnCapacity bool `json:"blockOnCapacity,omitempty"`
+
+	// SkipUnchanged skips re-applying objects whose live cluster state already matches the
+	// rendered manifest, shortening recovery after a partial failure on big targets.
+	// +optional
+	SkipUnchanged bool `json:"skipUnchanged,omitempty"`
+
+	// CheckDeterminism renders each target twice and flags targets whose output differs
+	// between renders - e.g. generators with random suffixes - since nondeterminism breaks
+	// diffing, pruning, and no-op detection.
+	// +optional
+	CheckDeterminism bool `json:"checkDeterminism,omitempty"`
+
+	// StatusPlugins maps group/Kind to an exec plugin reporting rollout status for kinds
+	// without a built-in status viewer.  The plugin receives the live object JSON on stdin
+	// and prints a status message; exit code 0 means done, 10 means still progressing.
+	// +optional
+	StatusPlugins map[string]string `json:"statusPlugins,omitempty"`
+
+	// DetectNoop skips the rollout wait loop when the apply reported every object unchanged
+	// and labels the issue no-changes, saving time on merges that don't affect the cluster.
+	// +optional
+	DetectNoop bool `json:"detectNoop,omitempty"`
+
+	// StatusBranch is the repo branch to push a structured per-applier status file to on
+	// each state change, so git alone records deploy history.
+	// +optional
+	StatusBranch string `json:"statusBranch,omitempty"`
+
+	// SnapshotManifests stores a gzipped ConfigMap of the fully rendered manifests per
+	// commit in the applier's namespace, so operators can inspect and diff exactly what
+	// was applied.
+	// +optional
+	SnapshotManifests bool `json:"snapshotManifests,omitempty"`
+
+	// Embedded applies and diffs in-process through the API instead of execing kubectl,
+	// so the applier image needs no external binaries.  Targets default to the raw
+	// renderer.
+	// +optional
+	Embedded bool `json:"embedded,omitempty"`
+
+	// RenderSandbox is a wrapper command renders are exec'd through - e.g. bwrap or
+	// nsjail flags - so exec plugins in a malicious PR can't exfiltrate the applier's
+	// credentials during kustomize build.
+	// +optional
+	RenderSandbox string `json:"renderSandbox,omitempty"`
+
+	// MaxParallelTargets limits how many parallel targets roll out at a time, preventing
+	// large releases from saturating cluster capacity and image registries.  Zero rolls out
+	// all parallel targets at once.
+	// +optional
+	MaxParallelTargets int `json:"maxParallelTargets,omitempty"`
+
+	// CreateNamespaces creates and labels namespaces referenced by rendered objects that
+	// don't exist yet instead of failing the apply.
+	// +optional
+	CreateNamespaces bool `json:"createNamespaces,omitempty"`
+
+	// Prune deletes objects stamped by earlier commits that are no longer rendered.
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+
+	// PrunePropagation sets the deletion propagation policy per kind when pruning -
+	// Foreground, Background, or Orphan.  Kinds default to Background.
+	// +optional
+	PrunePropagation map[string]string `json:"prunePropagation,omitempty"`
+
+	// PruneGracePeriods overrides the deletion grace period seconds per kind when pruning.
+	// +optional
+	PruneGracePeriods map[string]int64 `json:"pruneGracePeriods,omitempty"`
+
+	// VerifyCommand is an external provenance verification command - e.g. cosign - run with
+	// each target path appended before rendering.  A non-zero exit fails the rollout.
+	// +optional
+	VerifyCommand string `json:"verifyCommand,omitempty"`
+
+	// SmokeTestCommand is a smoke test suite - e.g. a test Job or HTTP probes - run with
+	// each target path appended after the target's objects roll out.  A non-zero exit
+	// fails the rollout.
+	// +optional
+	SmokeTestCommand string `json:"smokeTestCommand,omitempty"`
+
+	// GitHubRequestsPerHour is the API request budget of the bot account.  The controller
+	// divides the budget across the appliers sharing the same GitCredentials so they
+	// collectively stay under the GitHub rate limit.
+	// +optional
+	GitHubRequestsPerHour int `json:"gitHubRequestsPerHour,omitempty"`
+}
+
+// ToolVersions pins the versions of the CLI tools the applier shells out to, replacing the
+// ones baked into the image
+type ToolVersions struct {
+	// Kubectl is the kubectl version to download, e.g. v1.12.3.  Empty uses the image's
+	// kubectl.
+	// +optional
+	Kubectl string `json:"kubectl,omitempty"`
+
+	// KubectlSHA256 maps architecture - amd64 or arm64 - to the expected sha256 digest of
+	// the kubectl binary.  Architectures without a digest are not verified.
+	// +optional
+	KubectlSHA256 map[string]string `json:"kubectlSHA256,omitempty"`
+
+	// Kustomize is the kustomize version to download, e.g. 1.0.11.  Empty uses the image's
+	// kustomize.
+	// +optional
+	Kustomize string `json:"kustomize,omitempty"`
+
+	// KustomizeSHA256 maps architecture - amd64 or arm64 - to the expected sha256 digest of
+	// the kustomize binary.  Architectures without a digest are not verified.
+	// +optional
+	KustomizeSHA256 map[string]string `json:"kustomizeSHA256,omitempty"`
+}
+
+type GitConfig struct {
+	// CommitterName is the git committer name used for write operations.
+	// +optional
+	CommitterName string `json:"committerName,omitempty"`
+
+	// CommitterEmail is the git committer email used for write operations.
+	// +optional
+	CommitterEmail string `json:"committerEmail,omitempty"`
+
+	// Proxy is an HTTP(S) proxy URL used for git and GitHub API traffic.
+	// +optional
+	Proxy string `json:"proxy,omitempty"`
+
+	// CABundle is a PEM file of additional CAs to trust for git and GitHub API traffic.
+	// +optional
+	CABundle string `json:"caBundle,omitempty"`
+
+	// APIEndpoint is the base URL for GitHub API requests, e.g. a ghproxy-style caching
+	// proxy that partitions its cache by access token.
+	// +optional
+	APIEndpoint string `json:"apiEndpoint,omitempty"`
+
+	// SyncStrategy controls how aggressively the git work tree is cleaned when syncing.
+	// force discards modified tracked files, removes untracked and ignored files, and
+	// initializes submodules.
+	// +optional
+	SyncStrategy string `json:"syncStrategy,omitempty"`
+
+	// Submodules initializes and updates git submodules when syncing, for repos vendoring
+	// bases via submodules.
+	// +optional
+	Submodules bool `json:"submodules,omitempty"`
+
+	// LFS pulls git-lfs content when syncing, for repos storing large files via LFS.
+	// +optional
+	LFS bool `json:"lfs,omitempty"`
+
+	// SparseCheckout limits the checkout to the apply targets plus the kustomize bases they
+	// reference, reducing clone time and disk usage in monorepos.
+	// +optional
+	SparseCheckout bool `json:"sparseCheckout,omitempty"`
 }
 
 type ContinuousApplyComponents struct {
 	// GitCredentials is a reference to the Secret containing the GitCredentials
 	GitCredentials `json:"gitCredentials"`
 
+	// Applier is deprecated and only read to migrate existing objects.  The reference to
+	// the generated Deployment is stored in status.
 	// +optional
 	Applier *v1.ObjectReference `json:"applier,omitempty"`
 
@@ -64,12 +365,80 @@ type ContinuousApplyComponents struct {
 type GitCredentials struct {
 	Secret v1.LocalObjectReference `json:"secret"`
 	Key    string                  `json:"key"`
+
+	// Mount projects the Secret into the applier as a file instead of an environment
+	// variable, keeping the token out of kubectl describe output and crash dumps.  The
+	// applier re-reads the file on every request, so Secret rotations take effect without
+	// a restart.
+	// +optional
+	Mount bool `json:"mount,omitempty"`
+
+	// TokenCommand fetches short-lived tokens at runtime instead of referencing a Secret -
+	// e.g. a vault read or gcloud secrets versions access invocation - so long-lived PATs
+	// never live in the cluster at all.  Takes precedence over Secret and Key.
+	// +optional
+	TokenCommand string `json:"tokenCommand,omitempty"`
+
+	// TokenTTL is how long a fetched token is cached before TokenCommand is re-run, e.g.
+	// 5m.  Empty uses 5 minutes.
+	// +optional
+	TokenTTL string `json:"tokenTTL,omitempty"`
 }
 
 // ContinuousApplyStatus defines the observed state of ContinuousApply
 type ContinuousApplyStatus struct {
 	CommitSHA string `json:"commitSHA"`
 	Issue     int    `json:"issue"`
+
+	// Applier is a reference to the generated applier Deployment.
+	// +optional
+	Applier *v1.ObjectReference `json:"applier,omitempty"`
+
+	// Reason explains why the applier Deployment has not been created - e.g. the namespace
+	// or repo applier quota is exhausted.  Empty once the Deployment exists.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// LastPollTime is when the applier last successfully polled GitHub for new merges.  A
+	// stale value means the poller is stuck, e.g. on an expired token.
+	// +optional
+	LastPollTime *metav1.Time `json:"lastPollTime,omitempty"`
+
+	// NextScheduledSync is when the applier will next poll GitHub.
+	// +optional
+	NextScheduledSync *metav1.Time `json:"nextScheduledSync,omitempty"`
+
+	// LastAppliedTime is when the applier last finished a rollout.
+	// +optional
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+
+	// LastAppliedCommit is the commit of the last finished rollout.
+	// +optional
+	LastAppliedCommit string `json:"lastAppliedCommit,omitempty"`
+
+	// PollError is an actionable description of why polling GitHub is failing - e.g. a
+	// rejected token or a renamed repo.  Empty while polling succeeds.
+	// +optional
+	PollError string `json:"pollError,omitempty"`
+
+	// Queue lists merges newer than the applied commit, newest first, with scheduling
+	// notes.
+	// +optional
+	Queue []QueuedRollout `json:"queue,omitempty"`
+}
+
+// QueuedRollout is a merged PR waiting behind the applied commit.
+type QueuedRollout struct {
+	// PullRequest is the merged PR number.
+	PullRequest int `json:"pullRequest"`
+
+	// Commit is the merge commit.
+	Commit string `json:"commit"`
+
+	// Note explains the scheduling decision - e.g. rolling out next, a match filter
+	// mismatch, or a revert jumping the queue.
+	// +optional
+	Note string `json:"note,omitempty"`
 }
 
 type Repo struct {
@@ -79,12 +448,63 @@ type Repo struct {
 
 type ApplyTarget struct {
 	Path string `json:"path"`
+
+	// RolloutType overrides the Applier rollout type for this target.
+	// +optional
+	RolloutType string `json:"rolloutType,omitempty"`
+
+	// Pause overrides the Applier pause between rollout status checks for this target.
+	// +optional
+	Pause string `json:"pause,omitempty"`
+
+	// Window holds this target until a daily clock window opens, formatted as
+	// HH:MM-HH:MM with an optional IANA timezone - e.g. "02:00-04:00 America/New_York"
+	// for database migrations.
+	// +optional
+	Window string `json:"window,omitempty"`
+
+	// PauseAfter pauses the rollout after this target completes until a collaborator
+	// comments /continue on the rollout issue, for manual verification steps.
+	// +optional
+	PauseAfter bool `json:"pauseAfter,omitempty"`
+
+	// Namespace overrides metadata.namespace for all namespaced objects rendered from this target.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Renderer selects the renderer for this target - e.g. kustomize or raw.  Defaults to
+	// kustomize.
+	// +optional
+	Renderer string `json:"renderer,omitempty"`
+
+	// Checksum is the expected sha256 digest of this target's source files, verified before
+	// rendering - defense against repo tampering.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// DisallowPlaintextSecrets fails the rollout if this target renders a Secret with inline
+	// plaintext data.
+	// +optional
+	DisallowPlaintextSecrets bool `json:"disallowPlaintextSecrets,omitempty"`
+
+	// Selector applies only the rendered objects matching the label selector, so one
+	// kustomization can be split across ContinuousApply objects.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// ExcludeKinds drops rendered objects of the kinds - Kind or group/Kind.
+	// +optional
+	ExcludeKinds []string `json:"excludeKinds,omitempty"`
 }
 
 type GitActions struct {
 	// State may be one of "closed", "open"
 	SetState string `json:"setState"`
 
+	// SetMilestone sets the issue or PR milestone by title.
+	// +optional
+	SetMilestone string `json:"setMilestone,omitempty"`
+
 	AddLabels    []string `json:"addLabels"`
 	AddAssignees []string `json:"addAssignees"`
 
@@ -47,6 +47,47 @@ type IssueManagerSpec struct {
 	StatusReporters []*StatusReporters `json:"statusReporters"`
 
 	Label string `json:"label"`
+
+	// AssignOwners assigns newly opened issues to the CODEOWNERS of the paths changed by
+	// the PR instead of the static OpenActions assignee list.
+	// +optional
+	AssignOwners bool `json:"assignOwners,omitempty"`
+
+	// PathOwners maps path prefixes to the assignees paged for their component's rollout.
+	// Takes precedence over CODEOWNERS entries.
+	// +optional
+	PathOwners map[string][]string `json:"pathOwners,omitempty"`
+
+	// IssueTemplate is a markdown go-template for the body of rollout issues, replacing the
+	// built-in template.  It is executed with the PullRequest, Commit, ChangedPaths, and
+	// StatusReporters fields.  The [pull-request] and [commit] header lines are always
+	// prepended so issues can be matched back to their PRs.
+	// +optional
+	IssueTemplate string `json:"issueTemplate,omitempty"`
+
+	// ReleaseView additionally maintains a single "Release <commit>" issue aggregating the
+	// status of every applier commenting on the rollout issue - e.g. dev, staging, and
+	// prod - giving one place to watch a release progress.
+	// +optional
+	ReleaseView bool `json:"releaseView,omitempty"`
+
+	// Retention configures garbage collection of old managed issues and stale bot comments.
+	// +optional
+	Retention RetentionPolicy `json:"retention,omitempty"`
+}
+
+// RetentionPolicy configures garbage collection of the issues and comments the manager
+// accumulates over many rollouts
+type RetentionPolicy struct {
+	// CloseIssuesAfter closes and locks managed issues that have not been updated for this
+	// duration, e.g. 720h.  Empty disables issue cleanup.
+	// +optional
+	CloseIssuesAfter string `json:"closeIssuesAfter,omitempty"`
+
+	// DeleteSupersededCommentsAfter deletes superseded bot status comments that have not been
+	// updated for this duration.  Empty disables comment cleanup.
+	// +optional
+	DeleteSupersededCommentsAfter string `json:"deleteSupersededCommentsAfter,omitempty"`
 }
 
 type StatusReporters struct {
@@ -61,6 +102,14 @@ type StatusReporters struct {
 	CompleteLabels []string `json:"completeLabels"`
 
 	WaitFor []string `json:"waitFor"`
+
+	// Description is shown under the reporter in the rollout issue.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// RunbookURL links the runbook to consult when the reporter fails.
+	// +optional
+	RunbookURL string `json:"runbookURL,omitempty"`
 }
 
 type IssueManagerComponents struct {
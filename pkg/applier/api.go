@@ -0,0 +1,150 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pwittrock/continuous-apply/pkg/git"
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CommentSink receives each rendered rollout status update during a rollout.  The GitHub
+// status comment is always updated; embedders may set a sink to mirror the rendered
+// markdown elsewhere.
+type CommentSink interface {
+	Publish(body string) error
+}
+
+// StatusSink observes rollout state transitions - In Progress, Complete, Failed.  The
+// applier Deployment annotations are always updated; embedders may set a sink to observe
+// the transitions as well.
+type StatusSink interface {
+	SetState(state string)
+}
+
+// Options configures an Applier embedded in another controller or CLI
+type Options struct {
+	// Name identifies the rollout in comments and annotations
+	Name string
+
+	// Owner, Repo, and AccessToken identify the GitHub repo holding the config
+	Owner       string
+	Repo        string
+	AccessToken string
+
+	// User is the GitHub user the status comment is posted as
+	User string
+
+	// IssueNum is the issue or PR the status comment is posted on
+	IssueNum int
+
+	// ApplyTargets are the kustomize paths to apply
+	ApplyTargets []string
+
+	// RolloutType may be sequential or parallel
+	RolloutType string
+
+	// Pause is the time between rollout status checks
+	Pause time.Duration
+
+	// K8sClient is used for rollout status checks
+	K8sClient client.Client
+
+	// Comments optionally receives each rendered status update
+	Comments CommentSink
+
+	// Status optionally observes rollout state transitions
+	Status StatusSink
+}
+
+// Result summarizes a completed rollout
+type Result struct {
+	// Status is the final rollout status - Complete or Failed
+	Status string
+
+	// Commit is the commit that was rolled out
+	Commit string
+
+	// Rollouts holds the per-target and per-object outcomes
+	Rollouts *rollout.Rollouts
+
+	// Duration is how long the rollout took
+	Duration time.Duration
+}
+
+// New constructs an Applier from the options so other controllers and CLIs can embed the
+// apply+wait+comment machinery without constructing Poller internals
+func New(o Options) (*Applier, error) {
+	// An explicit token takes precedence; otherwise NewManager reads the token from the
+	// environment
+	m := &git.GitManager{Owner: o.Owner, Repo: o.Repo, AccessToken: strings.TrimSpace(o.AccessToken)}
+	if o.AccessToken == "" {
+		var err error
+		if m, err = git.NewManager(o.Owner, o.Repo, ""); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.ConfigureTransport(); err != nil {
+		return nil, err
+	}
+	return &Applier{
+		Name:         o.Name,
+		User:         o.User,
+		IssueNum:     o.IssueNum,
+		ApplyTargets: o.ApplyTargets,
+		RolloutType:  o.RolloutType,
+		Pause:        o.Pause,
+		GitClient:    m,
+		K8sClient:    o.K8sClient,
+		Comments:     o.Comments,
+		Status:       o.Status,
+	}, nil
+}
+
+// RunContext rolls out the commit and reports the result.  Cancelling the context aborts
+// the rollout between status checks.
+func (a *Applier) RunContext(ctx context.Context, commit string) (*Result, error) {
+	started := time.Now()
+	if commit != "" {
+		a.GitClient.Commit = commit
+	}
+	a.ctx = ctx
+	defer func() { a.ctx = nil }()
+
+	err := a.Run()
+	res := &Result{
+		Commit:   a.GitClient.Commit,
+		Duration: time.Since(started),
+		Rollouts: a.lastRollouts,
+	}
+	if a.lastRollouts != nil {
+		res.Status = a.lastRollouts.Status
+	}
+	return res, err
+}
+
+// canceled returns the context's error if the embedding caller canceled the rollout
+func (a *Applier) canceled() error {
+	if a.ctx == nil {
+		return nil
+	}
+	return a.ctx.Err()
+}
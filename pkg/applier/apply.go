@@ -18,16 +18,23 @@ package applier
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
 	"fmt"
 	"log"
 	"os/exec"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/google/go-github/github"
+	"github.com/pwittrock/continuous-apply/pkg/audit"
 	"github.com/pwittrock/continuous-apply/pkg/git"
 	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/dynamic"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -59,6 +66,9 @@ type Applier struct {
 	// BeforeSetState is the state to set before starting the rollout
 	BeforeSetState string
 
+	// BeforeSetMilestone is the milestone to set before starting the rollout
+	BeforeSetMilestone string
+
 	// AfterAddLabels are labels to add after completing the rollout
 	AfterAddLabels []string
 
@@ -74,12 +84,261 @@ type Applier struct {
 	// AfterSetState is the state to set after completing the rollout
 	AfterSetState string
 
+	// AfterSetMilestone is the milestone to set after completing the rollout
+	AfterSetMilestone string
+
 	// Pause is the time to wait between checking status of a rollout
 	Pause time.Duration
 
 	RolloutType string
+
+	// TargetRolloutTypes overrides RolloutType for specific targets, keyed by target path
+	TargetRolloutTypes map[string]string
+
+	// TargetPauses overrides Pause for specific targets, keyed by target path
+	TargetPauses map[string]time.Duration
+
+	// TargetWindows holds targets until a daily clock window - HH:MM-HH:MM with an
+	// optional IANA timezone - opens, keyed by target path.  E.g. database migrations
+	// only between 02:00 and 04:00 local.
+	TargetWindows map[string]string
+
+	// TargetPauseAfter pauses the rollout after the target completes until a collaborator
+	// comments /continue on the rollout issue, keyed by target path
+	TargetPauseAfter map[string]bool
+
+	// TargetSelectors applies only the rendered objects matching the label selector, keyed
+	// by target path.  Repeated selector terms are ANDed.
+	TargetSelectors map[string]string
+
+	// TargetExcludeKinds drops rendered objects of the kinds - Kind or group/Kind - keyed
+	// by target path
+	TargetExcludeKinds map[string][]string
+
+	// TargetNamespaces overrides metadata.namespace for the namespaced objects rendered from
+	// specific targets, keyed by target path
+	TargetNamespaces map[string]string
+
+	// TargetRenderers selects the registered Renderer for specific targets, keyed by target
+	// path.  Targets default to kustomize.
+	TargetRenderers map[string]string
+
+	// TargetChecksums are expected sha256 digests of each target's source files, keyed by
+	// target path and verified before rendering - defense against repo tampering.  Targets
+	// without an entry are not checked.
+	TargetChecksums map[string]string
+
+	// VerifyCommand is an external provenance verification command - e.g. cosign - run with
+	// each target path appended before rendering.  A non-zero exit fails the rollout.
+	VerifyCommand string
+
+	// SmokeTestCommand is a smoke test suite - e.g. a test Job or HTTP probes - run with
+	// each target path appended after the target's objects roll out.  A non-zero exit fails
+	// the rollout and runs the target's sync-fail hooks.
+	SmokeTestCommand string
+
+	// ImagePins replaces the tag or digest of rendered container images, keyed by image name.
+	// Pins are collected from /pin-image directives in PR comments.
+	ImagePins map[string]string
+
+	// DisallowPlaintextSecrets fails the rollout if a target renders a Secret with inline
+	// plaintext data, keyed by target path
+	DisallowPlaintextSecrets map[string]bool
+
+	// AllowedKinds fails the rollout if a rendered object's kind is not in the list.
+	// Empty allows all kinds.
+	AllowedKinds []string
+
+	// DeniedKinds fails the rollout if a rendered object's kind is in the list
+	DeniedKinds []string
+
+	// ScanManifests runs configuration checks on rendered objects - privileged containers,
+	// host namespaces, hostPath volumes, missing resource limits - and posts the findings to
+	// the issue comment
+	ScanManifests bool
+
+	// ScanBlockSeverity fails the rollout on findings of this severity or higher - warning
+	// or critical.  Empty posts findings without blocking.
+	ScanBlockSeverity string
+
+	// PreflightAdmission server-side dry-runs each object before applying anything, so
+	// admission refusals - e.g. Pod Security violations - surface up front instead of
+	// failing the rollout halfway through
+	PreflightAdmission bool
+
+	// CheckCapacity compares the aggregate cpu and memory requests being added against
+	// allocatable cluster capacity and namespace ResourceQuotas before applying, warning in
+	// the comment when the rollout would be unschedulable
+	CheckCapacity bool
+
+	// BlockOnCapacity fails the rollout instead of warning when CheckCapacity finds the
+	// rollout would be unschedulable
+	BlockOnCapacity bool
+
+	// SkipUnchanged skips re-applying objects whose live cluster state already matches the
+	// rendered manifest, shortening recovery after a partial failure on big targets
+	SkipUnchanged bool
+
+	// CheckDeterminism renders each target twice and flags targets whose output differs
+	// between renders - e.g. generators with random suffixes - since nondeterminism breaks
+	// diffing, pruning, and no-op detection
+	CheckDeterminism bool
+
+	// DetectNoop skips the rollout wait loop when the apply reported every object unchanged
+	// and labels the issue no-changes, saving time on merges that don't affect the cluster
+	DetectNoop bool
+
+	// MaxParallelTargets limits how many parallel targets roll out at a time.  Zero rolls
+	// out all parallel targets at once.
+	MaxParallelTargets int
+
+	// CreateNamespaces creates namespaces referenced by rendered objects that don't exist
+	// yet instead of failing the apply
+	CreateNamespaces bool
+
+	// Prune deletes objects this applier stamped in earlier commits that are no longer
+	// rendered
+	Prune bool
+
+	// PrunePropagation sets the deletion propagation policy per kind when pruning -
+	// Foreground, Background, or Orphan.  Kinds default to Background.
+	PrunePropagation map[string]string
+
+	// PruneGracePeriods overrides the deletion grace period seconds per kind when pruning
+	PruneGracePeriods map[string]int64
+
+	// DynamicClient deletes pruned objects of arbitrary kinds with per-kind DeleteOptions
+	DynamicClient dynamic.ClientPool
+
+	// Embedded applies and diffs in-process through the API instead of execing kubectl,
+	// so the applier runs from a scratch image with no external binaries.  Targets default
+	// to the raw renderer since kustomize is unavailable in such images.
+	Embedded bool
+
+	// RenderSandbox is a wrapper command renders are exec'd through - e.g. bwrap or
+	// nsjail flags providing a read-only filesystem, seccomp, and no network - so exec
+	// plugins in a malicious PR can't exfiltrate the applier's credentials.  Sandboxed
+	// renders also run with credential material stripped from the environment.  Empty
+	// renders directly.
+	RenderSandbox string
+
+	// PublishSummary uploads a JSON summary of each rollout as a gist linked from the comment
+	PublishSummary bool
+
+	// ThreadComments posts a fresh status comment per rollout, minimizing the previous one,
+	// and reacts to the comment as the rollout starts and finishes
+	ThreadComments bool
+
+	// ObjectURLTemplate renders each object's name in the comment as a link to a dashboard.
+	// {{namespace}}, {{kind}}, and {{name}} are substituted per object - e.g.
+	// https://console/workloads/{{namespace}}/{{kind}}/{{name}}.  Empty disables links.
+	ObjectURLTemplate string
+
+	// PrometheusURL is the base URL of the Prometheus queried for object readiness gates.
+	// Empty disables readiness queries.
+	PrometheusURL string
+
+	// ProgressNamespace is the namespace of the applier Deployment to annotate with rollout
+	// progress.  Empty disables progress annotations.
+	ProgressNamespace string
+
+	// SnapshotNamespace is the namespace to store a gzipped ConfigMap of the fully rendered
+	// manifests per commit in.  Empty disables snapshots.
+	SnapshotNamespace string
+
+	// StatusBranch is the repo branch to push a structured per-applier status file to on
+	// each state change, recording deploy history in git.  Empty disables the mirror.
+	StatusBranch string
+
+	// DryRun runs the full pipeline — render, validate, comment — without persisting
+	// anything to the cluster.  May be "client" or "server".
+	DryRun string
+
+	// Audit optionally records every apply and delete
+	Audit *audit.Logger
+
+	// Kubeconfig is an explicit kubeconfig path used for both status checks and applies.
+	// Empty uses the in-cluster config.
+	Kubeconfig string
+
+	// Context is the kubeconfig context used for both status checks and applies
+	Context string
+
+	// Comments optionally receives each rendered status update in addition to the GitHub
+	// comment, for embedders
+	Comments CommentSink
+
+	// Status optionally observes rollout state transitions in addition to the applier
+	// Deployment annotations, for embedders
+	Status StatusSink
+
+	// targetDurations records how long past rollouts of each target took, used to estimate
+	// the time remaining in the comment
+	targetDurations map[string][]time.Duration
+
+	// lastRollouts holds the outcome of the most recent Run for RunContext
+	lastRollouts *rollout.Rollouts
+
+	// rolloutStarted is when the current Run began, for status reporting
+	rolloutStarted time.Time
+
+	// lastStatusPush fingerprints the rollout states last pushed to the status branch, so
+	// pushes happen on state transitions rather than every comment update
+	lastStatusPush string
+
+	// ctx cancels the rollout when set by RunContext
+	ctx context.Context
+}
+
+// kubectl returns a kubectl command with the configured kubeconfig and context flags
+func (a *Applier) kubectl(args ...string) *exec.Cmd {
+	var flags []string
+	if a.Kubeconfig != "" {
+		flags = append(flags, "--kubeconfig", a.Kubeconfig)
+	}
+	if a.Context != "" {
+		flags = append(flags, "--context", a.Context)
+	}
+	return exec.Command("kubectl", append(flags, args...)...)
+}
+
+// kubectlApply returns a kubectl apply command reading objects from stdin, with the
+// configured dry-run flag if one is set
+func (a *Applier) kubectlApply() *exec.Cmd {
+	args := []string{"apply", "-f", "-"}
+	switch a.DryRun {
+	case "client":
+		args = append(args, "--dry-run")
+	case "server":
+		args = append(args, "--server-dry-run")
+	}
+	return a.kubectl(args...)
+}
+
+// targetRolloutType returns the rollout type for the target path, falling back to the Applier default
+func (a *Applier) targetRolloutType(path string) string {
+	if t, found := a.TargetRolloutTypes[path]; found && t != "" {
+		return t
+	}
+	if a.RolloutType == "" {
+		return "sequential"
+	}
+	return a.RolloutType
+}
+
+// targetPause returns the pause between rollout status checks for the target path, falling back
+// to the Applier default
+func (a *Applier) targetPause(path string) time.Duration {
+	if p, found := a.TargetPauses[path]; found {
+		return p
+	}
+	return a.Pause
 }
 
+// objectDurations exports per-object rollout durations in seconds on /debug/vars, keyed by
+// target path and object
+var objectDurations = expvar.NewMap("object_duration_seconds")
+
 const doneIcon = "![done](https://material.io/tools/icons/static/icons/twotone-done-24px.svg)"
 const inProgressIcon = "![inprogress](https://material.io/tools/icons/static/icons/twotone-cached-24px.svg)"
 
@@ -106,6 +365,12 @@ func (a *Applier) beforeActions() error {
 		}
 	}
 
+	if a.BeforeSetMilestone != "" {
+		if err := a.GitClient.SetMilestone(a.IssueNum, a.BeforeSetMilestone); err != nil {
+			return fmt.Errorf("failed to set milestone %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -133,10 +398,24 @@ func (a *Applier) afterActions() error {
 			return fmt.Errorf("failed to set issue state %v", err)
 		}
 	}
+
+	if a.AfterSetMilestone != "" {
+		if err := a.GitClient.SetMilestone(a.IssueNum, a.AfterSetMilestone); err != nil {
+			return fmt.Errorf("failed to set milestone %v", err)
+		}
+	}
 	return nil
 }
 
 func (a *Applier) Run() error {
+	started := time.Now()
+	a.rolloutStarted = started
+
+	// Restore per-target rollout duration history persisted on the applier Deployment
+	if a.targetDurations == nil {
+		a.targetDurations = a.loadDurations()
+	}
+
 	// Sync the repo
 	if err := a.GitClient.SyncRepo(); err != nil {
 		return err
@@ -150,7 +429,7 @@ func (a *Applier) Run() error {
 	}
 
 	// Get the GH comment to update with the Status
-	comment, err := a.GitClient.GetComment(a.Name, a.User, a.IssueNum)
+	comment, err := a.getComment()
 	if err != nil {
 		return err
 	}
@@ -160,15 +439,53 @@ func (a *Applier) Run() error {
 		Name:   a.Name,
 		Icon:   inProgressIcon,
 	}
+	a.lastRollouts = ros
+
+	// Publish progress to the cluster so dashboards can follow along without GitHub access
+	a.setProgress(ros.Status)
+	defer func() {
+		if ros.Status == "In Progress" {
+			ros.Status = "Failed"
+		}
+		a.setProgress(ros.Status)
+	}()
+
+	if a.ThreadComments {
+		_ = a.GitClient.ReactToComment(comment, "rocket")
+		defer func() {
+			if ros.Status == "Complete" {
+				_ = a.GitClient.ReactToComment(comment, "hooray")
+			} else {
+				_ = a.GitClient.ReactToComment(comment, "confused")
+			}
+		}()
+	}
+	selfUpdate := false
 	for _, path := range a.ApplyTargets {
-		log.Printf("kustomizing %s\n", path)
+		// Verify the provenance of the target's sources before rendering anything from them
+		if err := a.verifyTarget(path); err != nil {
+			return err
+		}
+
+		log.Printf("rendering %s\n", path)
 
-		// Kustomize the objects
-		objects, err := a.kustomize(path)
+		// Render the objects with the target's renderer
+		objects, err := a.render(path)
 		if err != nil {
 			return err
 		}
 
+		// Render again and compare so nondeterministic targets are flagged before their
+		// unstable output breaks diffing, pruning, and no-op detection
+		deterministic := true
+		if a.CheckDeterminism {
+			again, err := a.render(path)
+			if err != nil {
+				return err
+			}
+			deterministic = renderHash(objects) == renderHash(again)
+		}
+
 		log.Printf("adding %d items to rollout\n", len(objects))
 
 		// Get each of the rollouts
@@ -177,26 +494,324 @@ func (a *Applier) Run() error {
 			return err
 		}
 		ro.Path = path
+		if !deterministic {
+			log.Printf("target %s renders nondeterministically\n", path)
+			ro.Warnings = append(ro.Warnings,
+				"target renders nondeterministically; diffing, pruning, and no-op detection may misbehave")
+		}
+		ro.ExpectedDuration = a.expectedDuration(path)
+		ro.MedianDuration = a.medianDuration(path)
+
+		// Override the namespace of the rendered objects if the target specifies one
+		if ns := a.TargetNamespaces[path]; ns != "" {
+			for _, o := range append(ro.Objects, ro.Hooks...) {
+				if rollout.IsClusterScoped(o.Kind) {
+					continue
+				}
+				if err := o.SetNamespace(ns); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Drop rendered objects the target's include/exclude filters reject
+		if err := a.filterObjects(path, ro); err != nil {
+			return err
+		}
+
+		// Fail the rollout if any rendered object's kind is not admitted
+		if len(a.AllowedKinds) > 0 || len(a.DeniedKinds) > 0 {
+			var violations []string
+			for _, o := range append(ro.Objects, ro.Hooks...) {
+				if !a.kindAllowed(o.Kind) {
+					o.ApplyStatus = "refused: kind not allowed"
+					violations = append(violations, o.Display())
+				}
+			}
+			if len(violations) > 0 {
+				ro.Status = "Failed"
+				ros.Status = "Failed"
+				ros.Rollouts = append(ros.Rollouts, ro)
+				_, _ = a.updateComment(comment, ros)
+				return fmt.Errorf("kinds are not allowed in %s: %s",
+					path, strings.Join(violations, ", "))
+			}
+		}
+
+		// Fail the rollout if the target disallows plaintext Secrets and any were rendered
+		if a.DisallowPlaintextSecrets[path] {
+			var violations []string
+			for _, o := range append(ro.Objects, ro.Hooks...) {
+				if o.HasPlaintextSecretData() {
+					o.ApplyStatus = "refused: plaintext Secret data"
+					violations = append(violations, o.Display())
+				}
+			}
+			if len(violations) > 0 {
+				ro.Status = "Failed"
+				ros.Status = "Failed"
+				ros.Rollouts = append(ros.Rollouts, ro)
+				_, _ = a.updateComment(comment, ros)
+				return fmt.Errorf("plaintext Secret data is not allowed in %s: %s",
+					path, strings.Join(violations, ", "))
+			}
+		}
+
+		// Scan the rendered objects for insecure configuration
+		if a.ScanManifests {
+			var violations []string
+			for _, o := range append(ro.Objects, ro.Hooks...) {
+				for _, f := range o.SecurityFindings() {
+					ro.Findings = append(ro.Findings,
+						fmt.Sprintf("%s: %s - %s", f.Severity, o.Display(), f.Message))
+					if a.scanBlocked(f.Severity) {
+						o.ApplyStatus = "refused: " + f.Message
+						violations = append(violations, o.Display())
+					}
+				}
+			}
+			if len(violations) > 0 {
+				ro.Status = "Failed"
+				ros.Status = "Failed"
+				ros.Rollouts = append(ros.Rollouts, ro)
+				_, _ = a.updateComment(comment, ros)
+				return fmt.Errorf("security findings block the rollout of %s: %s",
+					path, strings.Join(violations, ", "))
+			}
+		}
+
+		// Compare the requests being added against cluster capacity and quotas
+		if a.CheckCapacity && a.K8sClient != nil {
+			findings, err := a.checkCapacity(ro)
+			if err != nil {
+				return err
+			}
+			if len(findings) > 0 && a.BlockOnCapacity {
+				ro.Status = "Failed"
+				ros.Status = "Failed"
+				ro.Warnings = append(ro.Warnings, findings...)
+				ros.Rollouts = append(ros.Rollouts, ro)
+				_, _ = a.updateComment(comment, ros)
+				return fmt.Errorf("insufficient capacity for %s: %s",
+					path, strings.Join(findings, ", "))
+			}
+			ro.Warnings = append(ro.Warnings, findings...)
+		}
+
+		// A self-managing repo applies the objects that define continuous-apply itself after
+		// everything else and waits for the new controller to become healthy, instead of
+		// killing the applier mid-rollout.  Must run before stamping adds the applier label
+		// to every object.
+		if rollout.DeferSelf(ro.Objects) {
+			selfUpdate = true
+		}
+
+		// Stamp tracking metadata so applied objects can be traced back to their rollout
+		stampLabels := map[string]string{
+			rollout.AppliedByLabel:     a.Name,
+			rollout.AppliedCommitLabel: a.GitClient.Commit,
+		}
+		stampAnnotations := map[string]string{
+			rollout.AppliedIssueAnnotation: strconv.Itoa(a.IssueNum),
+			rollout.AppliedPathAnnotation:  path,
+		}
+		for _, o := range append(ro.Objects, ro.Hooks...) {
+			if err := o.Stamp(stampLabels, stampAnnotations); err != nil {
+				return err
+			}
+		}
+
+		// Create any missing namespaces the target's objects reference
+		if err := a.ensureNamespaces(ro); err != nil {
+			return err
+		}
+
+		// Render each object's name as a dashboard link in the comment
+		if a.ObjectURLTemplate != "" {
+			for _, o := range append(ro.Objects, ro.Hooks...) {
+				o.URL = objectURL(a.ObjectURLTemplate, o)
+			}
+		}
+
+		// Substitute pinned image tags and digests into the rendered objects
+		if len(a.ImagePins) > 0 {
+			for _, o := range append(ro.Objects, ro.Hooks...) {
+				if err := o.PinImages(a.ImagePins); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Dry-run the final objects against the apiserver's admission chain - including
+		// the namespace's Pod Security level - before applying anything, so rollouts
+		// don't fail halfway through with admission errors
+		if a.PreflightAdmission && a.DryRun == "" {
+			ro.Warnings = append(ro.Warnings, a.enforcedLevels(ro)...)
+			if violations := a.preflightViolations(ro); len(violations) > 0 {
+				ro.Status = "Failed"
+				ros.Status = "Failed"
+				ros.Rollouts = append(ros.Rollouts, ro)
+				_, _ = a.updateComment(comment, ros)
+				return fmt.Errorf("admission refused objects in %s: %s",
+					path, strings.Join(violations, ", "))
+			}
+		}
+
 		ros.Rollouts = append(ros.Rollouts, ro)
 	}
 
+	// Link objects rendered identically by multiple targets so shared bases are applied and
+	// polled once but shown under every target
+	a.markShared(ros)
+
 	if _, err := a.updateComment(comment, ros); err != nil {
 		return err
 	}
 
-	if a.RolloutType == "sequential" || a.RolloutType == "" {
-		for _, ro := range ros.Rollouts {
-			if err := a.applyAllSequential(comment, ro, ros); err != nil {
+	// Record exactly what is being applied for the commit before the rollout starts, so the
+	// snapshot exists even if the rollout fails part way
+	a.snapshotManifests(ros)
+
+	// Partition the rollouts by their target's rollout type.  Sequential targets are rolled
+	// out one at a time, then the parallel targets are rolled out together.
+	var sequential []*rollout.Rollout
+	var parallel []*rollout.Rollout
+	for _, ro := range ros.Rollouts {
+		if a.targetRolloutType(ro.Path) == "parallel" {
+			parallel = append(parallel, ro)
+		} else {
+			sequential = append(sequential, ro)
+		}
+	}
+
+	for _, ro := range sequential {
+		// Hold the target until its rollout window opens
+		if err := a.waitForWindow(comment, ro, ros); err != nil {
+			if a.canceled() != nil {
+				return a.interrupted(comment, ros, err)
+			}
+			return err
+		}
+		if err := a.runHooks(comment, ro, ros, rollout.HookPreSync); err != nil {
+			return err
+		}
+		apply := a.applyAllSequential
+		if a.targetRolloutType(ro.Path) == "blue-green" {
+			apply = a.applyAllBlueGreen
+		}
+		if err := apply(comment, ro, ros); err != nil {
+			if a.canceled() != nil {
+				return a.interrupted(comment, ros, err)
+			}
+			// Run the failure hooks before giving up on the rollout
+			_ = a.runHooks(comment, ro, ros, rollout.HookSyncFail)
+			return err
+		}
+		if err := a.runHooks(comment, ro, ros, rollout.HookPostSync); err != nil {
+			return err
+		}
+		// An interactive pause point lets an operator verify before the next target
+		if err := a.pausePoint(comment, ro, ros); err != nil {
+			if a.canceled() != nil {
+				return a.interrupted(comment, ros, err)
+			}
+			return err
+		}
+	}
+
+	// Roll out at most MaxParallelTargets targets at a time so large releases don't
+	// saturate cluster capacity and image registries
+	batch := len(parallel)
+	if a.MaxParallelTargets > 0 && a.MaxParallelTargets < batch {
+		batch = a.MaxParallelTargets
+	}
+	for i := 0; i < len(parallel); i += batch {
+		end := i + batch
+		if end > len(parallel) {
+			end = len(parallel)
+		}
+		group := parallel[i:end]
+
+		for _, ro := range group {
+			// Hold the batch until every member's rollout window opens
+			if err := a.waitForWindow(comment, ro, ros); err != nil {
+				if a.canceled() != nil {
+					return a.interrupted(comment, ros, err)
+				}
 				return err
 			}
 		}
-	} else {
-		if err := a.applyAllParallel(comment, ros); err != nil {
+		for _, ro := range group {
+			if err := a.runHooks(comment, ro, ros, rollout.HookPreSync); err != nil {
+				return err
+			}
+		}
+		if err := a.applyAllParallel(comment, ros, group); err != nil {
+			if a.canceled() != nil {
+				return a.interrupted(comment, ros, err)
+			}
+			// Run the failure hooks before giving up on the rollout
+			for _, ro := range group {
+				_ = a.runHooks(comment, ro, ros, rollout.HookSyncFail)
+			}
 			return err
 		}
+		for _, ro := range group {
+			if err := a.runHooks(comment, ro, ros, rollout.HookPostSync); err != nil {
+				return err
+			}
+		}
+		for _, ro := range group {
+			// An interactive pause point lets an operator verify before the next batch
+			if err := a.pausePoint(comment, ro, ros); err != nil {
+				if a.canceled() != nil {
+					return a.interrupted(comment, ros, err)
+				}
+				return err
+			}
+		}
 	}
+	// Delete objects from earlier commits that are no longer rendered, waiting for the
+	// deletions to finish before declaring the rollout complete
+	if err := a.pruneObjects(ros); err != nil {
+		return err
+	}
+
+	// The rollout replaced parts of continuous-apply itself and the new controller is
+	// healthy.  It may restart this applier once the comment is finalized - the signal
+	// handler posts the resume comment if that lands mid-poll.
+	if selfUpdate {
+		log.Printf("applied an update to continuous-apply itself, handing off to the new controller\n")
+	}
+
+	// Label merges that didn't change the cluster so readers can skip them
+	if a.DetectNoop {
+		noop := true
+		for _, ro := range ros.Rollouts {
+			for _, o := range append(ro.Objects, ro.Hooks...) {
+				if !o.Unchanged() {
+					noop = false
+				}
+			}
+		}
+		if noop {
+			log.Printf("rollout was a no-op, labeling issue no-changes\n")
+			if err := a.GitClient.AddLabels(a.IssueNum, "no-changes"); err != nil {
+				log.Printf("%v", err)
+			}
+		}
+	}
+
 	ros.Status = "Complete"
 	ros.Icon = doneIcon
+
+	// Publish the machine-readable rollout summary
+	if a.PublishSummary {
+		if err := a.publishSummary(ros, started); err != nil {
+			return err
+		}
+	}
+
 	if comment, err = a.updateComment(comment, ros); err != nil {
 		return err
 	}
@@ -208,13 +823,130 @@ func (a *Applier) Run() error {
 	return nil
 }
 
-func (a *Applier) kustomize(path string) ([]string, error) {
-	out, err := exec.Command("kustomize", "build", path).CombinedOutput()
+// interrupted marks the rollout as interrupted in the comment when the applier is shutting
+// down, so readers know the rollout stopped cleanly and will resume
+func (a *Applier) interrupted(comment *github.IssueComment, ros *rollout.Rollouts, err error) error {
+	log.Printf("rollout interrupted, will resume\n")
+	ros.Status = "Interrupted - rollout will resume"
+	ros.Icon = inProgressIcon
+	_, _ = a.updateComment(comment, ros)
+	return err
+}
+
+// getComment returns the status comment to update.  In threading mode the previous status
+// comment is minimized and a fresh one is created for each rollout.
+func (a *Applier) getComment() (*github.IssueComment, error) {
+	if !a.ThreadComments {
+		return a.GitClient.GetComment(a.Name, a.User, a.IssueNum)
+	}
+
+	prev, err := a.GitClient.FindComment(a.Name, a.User, a.IssueNum)
 	if err != nil {
-		log.Printf("failed to kustomize %s\n", out)
 		return nil, err
 	}
-	return strings.Split(string(out), "---\n"), nil
+	if prev != nil {
+		if err := a.GitClient.MinimizeComment(prev, a.Name); err != nil {
+			return nil, err
+		}
+	}
+	return a.GitClient.CreateComment(a.Name, a.IssueNum)
+}
+
+// auditLog records an apply or delete of an object in the audit log
+func (a *Applier) auditLog(action string, o *rollout.Object, err error) {
+	a.Audit.Log(audit.Entry{
+		Actor:   a.User,
+		Action:  action,
+		Object:  o.Display(),
+		Commit:  a.GitClient.Commit,
+		Hash:    audit.Sha256(o.Raw),
+		Outcome: audit.Outcome(err),
+	})
+}
+
+// publishSummary uploads a JSON summary of the rollout as a gist and links it from the comment
+func (a *Applier) publishSummary(ros *rollout.Rollouts, started time.Time) error {
+	s := rollout.Summarize(ros, a.GitClient.Commit, started, time.Now())
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s", a.Name, a.GitClient.Commit)
+	url, err := a.GitClient.CreateGist(name, string(b))
+	if err != nil {
+		return fmt.Errorf("failed to publish rollout summary %v", err)
+	}
+	ros.SummaryURL = url
+	return nil
+}
+
+// recordDuration saves how long the target's rollout took to estimate future rollouts
+func (a *Applier) recordDuration(ro *rollout.Rollout) {
+	ro.Finished = time.Now()
+	if ro.Started.IsZero() {
+		return
+	}
+	for _, o := range append(ro.Objects, ro.Hooks...) {
+		if o.Started.IsZero() || o.Finished.IsZero() {
+			continue
+		}
+		f := new(expvar.Float)
+		f.Set(o.Finished.Sub(o.Started).Seconds())
+		objectDurations.Set(ro.Path+"|"+o.Display(), f)
+	}
+	if a.targetDurations == nil {
+		a.targetDurations = map[string][]time.Duration{}
+	}
+	durations := append(a.targetDurations[ro.Path], ro.Finished.Sub(ro.Started))
+	if len(durations) > maxDurationHistory {
+		durations = durations[len(durations)-maxDurationHistory:]
+	}
+	a.targetDurations[ro.Path] = durations
+	a.saveDurations()
+}
+
+// expectedDuration averages the durations of the target's past rollouts.  Zero if the target
+// has not rolled out before.
+func (a *Applier) expectedDuration(path string) time.Duration {
+	durations := a.targetDurations[path]
+	if len(durations) == 0 {
+		return 0
+	}
+	total := time.Duration(0)
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations))
+}
+
+// severityRank orders finding severities for threshold comparison
+var severityRank = map[string]int{"warning": 1, "critical": 2}
+
+// scanBlocked returns true if a finding of the severity should fail the rollout
+func (a *Applier) scanBlocked(severity string) bool {
+	if a.ScanBlockSeverity == "" {
+		return false
+	}
+	return severityRank[severity] >= severityRank[a.ScanBlockSeverity]
+}
+
+// kindAllowed returns true if objects of the kind may be applied by this Applier
+func (a *Applier) kindAllowed(kind string) bool {
+	for _, k := range a.DeniedKinds {
+		if k == kind {
+			return false
+		}
+	}
+	if len(a.AllowedKinds) == 0 {
+		return true
+	}
+	for _, k := range a.AllowedKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
 }
 
 func (a *Applier) getRollout(comment *github.IssueComment, objects ...string) (*rollout.Rollout, error) {
@@ -227,87 +959,66 @@ func (a *Applier) getRollout(comment *github.IssueComment, objects ...string) (*
 		if err != nil {
 			return ro, err
 		}
+		if obj.IsHook() {
+			// Hooks are run at their annotated phase instead of being applied with the other objects
+			ro.Hooks = append(ro.Hooks, obj)
+			continue
+		}
 		ro.Objects = append(ro.Objects, obj)
 	}
 
 	return ro, nil
 }
 
-func (a *Applier) applyAllParallel(comment *github.IssueComment, ros *rollout.Rollouts) error {
-	for _, ro := range ros.Rollouts {
+func (a *Applier) applyAllParallel(comment *github.IssueComment, ros *rollout.Rollouts, rollouts []*rollout.Rollout) error {
+	// Group the objects from all rollouts so wave ordering is honored across targets
+	var objects []*rollout.Object
+	for _, ro := range rollouts {
 		ro.Status = "In Progress"
 		ro.Icon = inProgressIcon
-		for _, o := range ro.Objects {
-			log.Printf("applying %s\n", o.Display())
-			// Apply the object
-			cmd := exec.Command("kubectl", "apply", "-f", "-")
-			cmd.Stdin = bytes.NewBuffer(o.Raw)
-			out, err := cmd.CombinedOutput()
-			log.Printf("%s", out)
-			o.ApplyStatus = strings.TrimSpace(string(out))
-			if err != nil {
-				_, _ = a.updateComment(comment, ros)
-				return fmt.Errorf("%v error applying %s", err, o.Raw)
-			}
-		}
+		ro.Started = time.Now()
+		objects = append(objects, ro.Objects...)
 	}
 
-	var err error
-	if comment, err = a.updateComment(comment, ros); err != nil {
-		return err
+	// Use the shortest pause of the targets being rolled out together
+	pause := a.Pause
+	for _, ro := range rollouts {
+		if p := a.targetPause(ro.Path); p < pause {
+			pause = p
+		}
 	}
 
-	done := false
-	for !done {
-		done = true
-		rodone := true
-		for _, ro := range ros.Rollouts {
-			for _, o := range ro.Objects {
-				// Wait for rollout to complete
-				viewer := rollout.GetStatusViewer(o.Object, a.K8sClient)
-				if viewer == nil {
-					o.RolloutStatus = "NA"
-					o.Done = true
-					continue
-				}
-
-				status, d, err := viewer.Status(o.NamespacedName, 0)
-				status = strings.TrimSpace(status)
-				o.Done = done
-
-				if err != nil {
-					o.RolloutStatus = fmt.Sprintf("error: %v", err)
-					_, _ = a.updateComment(comment, ros)
-					return fmt.Errorf("'%v' error getting rollout status for %s\n%T - %s %s",
-						err, o.JSON, o.Object, o.Name, o.Namespace)
-				}
-
-				if o.RolloutStatus != status {
-					log.Println(status)
-					o.RolloutStatus = status
-					o.RolloutStatusHistory = append(o.RolloutStatusHistory, fmt.Sprintf("*%s* - `%s`", time.Now().Format(time.RFC822), status))
-					if comment, err = a.updateComment(comment, ros); err != nil {
-						return err
-					}
-				}
+	// Apply the objects one wave at a time, waiting for each wave to rollout before the next
+	for _, wave := range rollout.Waves(objects) {
+		var err error
+		if comment, err = a.applyObjects(comment, ros, wave); err != nil {
+			return err
+		}
+		if comment, err = a.waitForObjects(comment, ros, wave, pause); err != nil {
+			return err
+		}
 
-				// Pause between checking status
-				if !d {
-					done = false
+		// Mark rollouts whose objects have all completed
+		for _, ro := range rollouts {
+			rodone := true
+			for _, o := range ro.Objects {
+				if !o.Done {
 					rodone = false
 				}
 			}
-			if rodone {
+			if rodone && ro.Status != "Complete" {
 				ro.Status = "Complete"
 				ro.Icon = doneIcon
+				a.recordDuration(ro)
 			}
 		}
-		if comment, err = a.updateComment(comment, ros); err != nil {
+	}
+
+	// Exercise the rolled-out workloads of each target before declaring them complete
+	for _, ro := range rollouts {
+		if err := a.smokeTest(comment, ro, ros); err != nil {
 			return err
 		}
-		if !done {
-			time.Sleep(a.Pause)
-		}
 	}
 
 	return nil
@@ -316,46 +1027,261 @@ func (a *Applier) applyAllParallel(comment *github.IssueComment, ros *rollout.Ro
 func (a *Applier) applyAllSequential(comment *github.IssueComment, ro *rollout.Rollout, ros *rollout.Rollouts) error {
 	ro.Status = "In Progress"
 	ro.Icon = inProgressIcon
+	ro.Started = time.Now()
+
+	// Apply the objects one wave at a time, waiting for each wave to rollout before the next
+	for _, wave := range rollout.Waves(ro.Objects) {
+		var err error
+		if comment, err = a.applyObjects(comment, ros, wave); err != nil {
+			return err
+		}
+		if comment, err = a.waitForObjects(comment, ros, wave, a.targetPause(ro.Path)); err != nil {
+			return err
+		}
+	}
 
-	for _, o := range ro.Objects {
+	// Exercise the rolled-out workloads before declaring the target complete
+	if err := a.smokeTest(comment, ro, ros); err != nil {
+		return err
+	}
+	ro.Status = "Complete"
+	ro.Icon = doneIcon
+	a.recordDuration(ro)
+
+	return nil
+}
+
+// applyObjects applies each of the objects with kubectl
+func (a *Applier) applyObjects(comment *github.IssueComment, ros *rollout.Rollouts, objects []*rollout.Object) (
+	*github.IssueComment, error) {
+
+	for _, o := range objects {
+		// Stop taking new objects if the applier is shutting down.  The in-flight object is
+		// never left half-applied because kubectl applies it whole.
+		if err := a.canceled(); err != nil {
+			return comment, err
+		}
+		// The object is identical to one an earlier target already applied
+		if o.Primary != nil {
+			o.Started = time.Now()
+			o.ApplyStatus = "applied by " + o.SharedWith
+			continue
+		}
+		if a.SkipUnchanged && a.unchanged(o) {
+			log.Printf("skipping unchanged %s\n", o.Display())
+			o.Started = time.Now()
+			o.ApplyStatus = "unchanged (skipped)"
+			continue
+		}
 		log.Printf("applying %s\n", o.Display())
+		o.Started = time.Now()
 		// Apply the object
-		cmd := exec.Command("kubectl", "apply", "-f", "-")
-		cmd.Stdin = bytes.NewBuffer(o.Raw)
-		out, err := cmd.CombinedOutput()
+		var out []byte
+		var err error
+		if a.Embedded {
+			var status string
+			status, err = a.embeddedApply(o)
+			if err != nil {
+				status = err.Error()
+			}
+			out = []byte(status)
+		} else {
+			cmd := a.kubectlApply()
+			cmd.Stdin = bytes.NewBuffer(o.Raw)
+			out, err = cmd.CombinedOutput()
+		}
 		log.Printf("%s", out)
 		o.ApplyStatus = strings.TrimSpace(string(out))
+		a.auditLog("apply", o, err)
 		if err != nil {
 			_, _ = a.updateComment(comment, ros)
-			return fmt.Errorf("%v error applying %s", err, o.Raw)
+			return comment, fmt.Errorf("%v error applying %s", err, o.Raw)
 		}
+		a.captureRevision(o)
 	}
 
-	var err error
-	if comment, err = a.updateComment(comment, ros); err != nil {
-		return err
+	return a.updateComment(comment, ros)
+}
+
+// captureRevision records the revision of the rollout the apply triggered, so the wait loop
+// polls the rollout we started rather than one a concurrent manual rollout replaced it with.
+// The revision annotation is only trustworthy once the deployment controller has observed
+// the applied spec, so the capture polls briefly and skips the pin if the controller is slow.
+func (a *Applier) captureRevision(o *rollout.Object) {
+	if a.K8sClient == nil || o.Kind != "Deployment" {
+		return
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deployment := &appsv1.Deployment{}
+		if err := a.K8sClient.Get(context.TODO(), o.NamespacedName, deployment); err != nil {
+			return
+		}
+		if deployment.Status.ObservedGeneration >= deployment.Generation {
+			if rev, err := rollout.Revision(deployment); err == nil {
+				o.Revision = rev
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// markShared marks duplicate objects - matched by group, version, kind, namespace, and
+// name - rendered by more than one target.  The object of the target that rolls out first
+// applies and polls on behalf of the rest, so the rollouts are walked in execution order -
+// sequential targets before parallel ones.
+func (a *Applier) markShared(ros *rollout.Rollouts) {
+	ordered := make([]*rollout.Rollout, 0, len(ros.Rollouts))
+	for _, ro := range ros.Rollouts {
+		if a.targetRolloutType(ro.Path) != "parallel" {
+			ordered = append(ordered, ro)
+		}
+	}
+	for _, ro := range ros.Rollouts {
+		if a.targetRolloutType(ro.Path) == "parallel" {
+			ordered = append(ordered, ro)
+		}
+	}
+
+	seen := map[string]*rollout.Object{}
+	paths := map[string]string{}
+	for _, ro := range ordered {
+		for _, o := range append(ro.Objects, ro.Hooks...) {
+			k := fmt.Sprintf("%s|%s|%s", o.GroupVersionKind, o.Namespace, o.Name)
+			if first, found := seen[k]; found {
+				o.Primary = first
+				o.SharedWith = paths[k]
+				continue
+			}
+			seen[k] = o
+			paths[k] = ro.Path
+		}
+	}
+}
+
+// objectURL expands the dashboard URL template for an object, substituting {{namespace}},
+// {{kind}}, and {{name}}
+func objectURL(tmpl string, o *rollout.Object) string {
+	return strings.NewReplacer(
+		"{{namespace}}", o.Namespace,
+		"{{kind}}", strings.ToLower(o.Kind),
+		"{{name}}", o.Name,
+	).Replace(tmpl)
+}
+
+// allUnchanged returns true if the apply reported every object unchanged
+func allUnchanged(objects []*rollout.Object) bool {
+	for _, o := range objects {
+		if !o.Unchanged() {
+			return false
+		}
 	}
+	return true
+}
+
+// unchanged returns true if the rendered object already matches the live cluster state, so
+// a retry can skip re-applying it.  kubectl diff exits zero only when there are no changes.
+func (a *Applier) unchanged(o *rollout.Object) bool {
+	if a.Embedded {
+		return a.embeddedUnchanged(o)
+	}
+	cmd := a.kubectl("diff", "-f", "-")
+	cmd.Stdin = bytes.NewBuffer(o.Raw)
+	return cmd.Run() == nil
+}
+
+// waitForObjects polls the rollout status of each of the objects until all are done
+func (a *Applier) waitForObjects(comment *github.IssueComment, ros *rollout.Rollouts, objects []*rollout.Object,
+	pause time.Duration) (*github.IssueComment, error) {
+
+	// Nothing was persisted, so there is no rollout status to wait for
+	if a.DryRun != "" {
+		for _, o := range objects {
+			o.RolloutStatus = "skipped (dry-run)"
+			o.Done = true
+			o.Finished = time.Now()
+		}
+		return a.updateComment(comment, ros)
+	}
+
+	// Nothing changed, so there is no rollout to wait for
+	if a.DetectNoop && allUnchanged(objects) {
+		for _, o := range objects {
+			o.RolloutStatus = "unchanged (no-op)"
+			o.Done = true
+			o.Finished = time.Now()
+		}
+		return a.updateComment(comment, ros)
+	}
+
+	// Adaptive polling state.  Objects whose status just changed are polled every pause
+	// while stable-but-not-done objects back off, reducing apiserver load on rollouts with
+	// hundreds of objects.
+	const maxBackoff = 8
+	intervals := map[*rollout.Object]time.Duration{}
+	nextCheck := map[*rollout.Object]time.Time{}
 
 	done := false
 	for !done {
+		// Stop polling if the embedding caller canceled the rollout
+		if err := a.canceled(); err != nil {
+			return comment, err
+		}
 		done = true
-		for _, o := range ro.Objects {
+		for _, o := range objects {
+			// Mirror the status of the object polling on this one's behalf
+			if o.Primary != nil {
+				o.RolloutStatus = o.Primary.RolloutStatus
+				o.Done = o.Primary.Done
+				if o.Done && o.Finished.IsZero() {
+					o.Finished = time.Now()
+				}
+				if !o.Done {
+					done = false
+				}
+				continue
+			}
+
+			// Skip stable objects that have backed off until their next check is due
+			if !o.Done && time.Now().Before(nextCheck[o]) {
+				done = false
+				continue
+			}
+
 			// Wait for rollout to complete
 			viewer := rollout.GetStatusViewer(o.Object, a.K8sClient)
-			if viewer == nil {
+			if viewer == nil && (o.ReadinessQuery == "" || a.PrometheusURL == "") {
 				o.RolloutStatus = "NA"
 				o.Done = true
+				if o.Finished.IsZero() {
+					o.Finished = time.Now()
+				}
 				continue
 			}
 
-			status, d, err := viewer.Status(o.NamespacedName, 0)
-			status = strings.TrimSpace(status)
+			status, d := "NA", true
+			var err error
+			if viewer != nil {
+				status, d, err = viewer.Status(o.NamespacedName, o.Revision)
+				status = strings.TrimSpace(status)
+			}
+			// Hold otherwise-done objects behind their readiness query
+			if err == nil && d && o.ReadinessQuery != "" && a.PrometheusURL != "" {
+				status, d = a.readinessGate(o, status)
+			}
 			o.Done = d
+			if d && o.Finished.IsZero() {
+				o.Finished = time.Now()
+			}
 
 			if err != nil {
 				o.RolloutStatus = fmt.Sprintf("error: %v", err)
 				_, _ = a.updateComment(comment, ros)
-				return fmt.Errorf("'%v' error getting rollout status for %s\n%T - %s %s",
+				return comment, fmt.Errorf("'%v' error getting rollout status for %s\n%T - %s %s",
 					err, o.JSON, o.Object, o.Name, o.Namespace)
 			}
 
@@ -364,26 +1290,38 @@ func (a *Applier) applyAllSequential(comment *github.IssueComment, ro *rollout.R
 				o.RolloutStatus = status
 				o.RolloutStatusHistory = append(o.RolloutStatusHistory, fmt.Sprintf("*%s* - `%s`", time.Now().Format(time.RFC822), status))
 				if comment, err = a.updateComment(comment, ros); err != nil {
-					return err
+					return comment, err
+				}
+				// The object is changing, poll it fast again
+				intervals[o] = pause
+			} else {
+				// The object is stable but not done, back off
+				next := intervals[o] * 2
+				if next < pause {
+					next = pause
 				}
+				if next > maxBackoff*pause {
+					next = maxBackoff * pause
+				}
+				intervals[o] = next
 			}
+			nextCheck[o] = time.Now().Add(intervals[o])
 
 			// Pause between checking status
 			if !d {
 				done = false
 			}
 		}
+		var err error
 		if comment, err = a.updateComment(comment, ros); err != nil {
-			return err
+			return comment, err
 		}
 		if !done {
-			time.Sleep(a.Pause)
+			time.Sleep(pause)
 		}
 	}
-	ro.Status = "Complete"
-	ro.Icon = doneIcon
 
-	return nil
+	return comment, nil
 }
 
 func (a *Applier) updateComment(comment *github.IssueComment, ro *rollout.Rollouts) (*github.IssueComment, error) {
@@ -393,6 +1331,19 @@ func (a *Applier) updateComment(comment *github.IssueComment, ro *rollout.Rollou
 		return nil, err
 	}
 
+	// Mirror the rendered status into the cluster for readers without GitHub access
+	a.setStatusConfigMap(b.String())
+
+	// Mirror the rendered status to the embedding caller's sink
+	if a.Comments != nil {
+		if err := a.Comments.Publish(b.String()); err != nil {
+			log.Printf("comment sink failed %v", err)
+		}
+	}
+
+	// Mirror state transitions onto the status branch
+	a.pushStatusBranch(ro)
+
 	// Update the comment
 	body := b.String()
 	comment.Body = &body
@@ -401,12 +1352,50 @@ func (a *Applier) updateComment(comment *github.IssueComment, ro *rollout.Rollou
 
 const issueTemplateBody = `
 ## {{ .Icon }} {{ .Name }} - *{{ .Status }}*
+{{ if .Progress }}{{ .Progress }}
+{{ end -}}
+{{ if .SummaryURL }}[rollout summary]({{ .SummaryURL }})
+{{ end -}}
 ---
 
-{{range $ro := .Rollouts }}### {{ $ro.Icon }} ` + "`{{ $ro.Path }}`" + ` - *{{ $ro.Status }}*
+{{range $ro := .Rollouts }}### {{ $ro.Icon }} ` + "`{{ $ro.Path }}`" + ` - *{{ $ro.Status }}*{{ if $ro.Progress }} ({{ $ro.Progress }}{{ if $ro.ETA }}, {{ $ro.ETA }} remaining{{ end }}){{ end }}
+{{ if $ro.Regression }}
+:warning: {{ $ro.Regression }}
+{{ end -}}
+{{ range $w := $ro.Warnings }}
+:warning: {{ $w }}
+{{ end -}}
+{{ if $ro.SmokeTest }}
+smoke tests: {{ $ro.SmokeTest }}
+{{ end -}}
+{{ if $ro.BlueGreen }}
+blue-green: {{ $ro.BlueGreen }}
+{{ end -}}
+{{ if $ro.CreatedNamespaces }}
+created namespaces:{{ range $ns := $ro.CreatedNamespaces }} ` + "`{{ $ns }}`" + `{{ end }}
+{{ end -}}
+{{ if $ro.Findings }}
+security findings:
+{{ range $f := $ro.Findings }}- {{ $f }}
+{{ end }}
+{{ end -}}
+{{ if $ro.Summarized }}
+{{ $ro.StatusCounts }}
+{{ end -}}
 
-{{ range $obj := $ro.Objects }}
-- [{{ if $obj.Done}}x{{else}} {{end}}] {{ $obj.Display }}
+{{ range $obj := $ro.Hooks }}
+- [{{ if $obj.Done}}x{{else}} {{end}}] *{{ $obj.Hook }} hook* {{ if $obj.URL }}[{{ $obj.Display }}]({{ $obj.URL }}){{ else }}{{ $obj.Display }}{{ end }}{{ if $obj.Duration }} _in {{ $obj.Duration }}_{{ end }}
+{{ if $obj.ApplyStatus }}  - ` + "**apply:** `{{ $obj.ApplyStatus}}`" + `
+{{ end -}}
+{{ if $obj.RolloutStatus }}  - ` + "**hook:** `{{ $obj.RolloutStatus}}`" + `
+{{range $h := $obj.RolloutStatusHistory }}    - {{ $h }}
+{{ end }}
+{{ end -}}
+{{ end }}
+{{ range $g := $ro.Groups }}{{ if $g.Name }}**{{ $g.Name }}** ({{ $g.Progress }})
+{{ end -}}
+{{ range $obj := $g.Objects }}
+- [{{ if $obj.Done}}x{{else}} {{end}}] {{ if $obj.URL }}[{{ $obj.Display }}]({{ $obj.URL }}){{ else }}{{ $obj.Display }}{{ end }}{{ if $obj.SharedWith }} _(shared with ` + "`{{ $obj.SharedWith }}`" + `)_{{ end }} _{{ $obj.State }}{{ if $obj.Duration }} in {{ $obj.Duration }}{{ end }}_
 {{ if $obj.ApplyStatus }}  - ` + "**apply:** `{{ $obj.ApplyStatus}}`" + `
 {{ end -}}
 {{ if $obj.RolloutStatus }}  - ` + "**rollout:** `{{ $obj.RolloutStatus}}`" + `
@@ -414,6 +1403,7 @@ const issueTemplateBody = `
 {{ end }}
 {{ end -}}
 {{ end }}
+{{ end -}}
 ---
 {{ end }}
 `
@@ -0,0 +1,137 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// applyAllBlueGreen rolls out a blue-green target.  The target's Deployments are renamed
+// and labeled with the next color and applied alongside the live color's; once the new
+// pods are ready and the smoke tests pass, the Service selectors are switched to the new
+// color and the old color is scaled down.  Both colors are tracked in the comment.
+func (a *Applier) applyAllBlueGreen(comment *github.IssueComment, ro *rollout.Rollout, ros *rollout.Rollouts) error {
+	ro.Status = "In Progress"
+	ro.Icon = inProgressIcon
+	ro.Started = time.Now()
+
+	live, next := a.liveColor(ro)
+
+	// Stamp the next color onto the objects, holding the Services back so traffic keeps
+	// flowing to the live color until the new pods are ready
+	type deployment struct{ namespace, name string }
+	var services, workloads []*rollout.Object
+	var retired []deployment
+	for _, o := range ro.Objects {
+		if o.Kind == "Deployment" && live != "" && live != next {
+			retired = append(retired, deployment{o.Namespace, o.Name + "-" + live})
+		}
+		if err := o.SetColor(next); err != nil {
+			return err
+		}
+		if o.Kind == "Service" {
+			services = append(services, o)
+		} else {
+			workloads = append(workloads, o)
+		}
+	}
+
+	// Apply the new color one wave at a time, waiting for each wave to rollout before the next
+	for _, wave := range rollout.Waves(workloads) {
+		var err error
+		if comment, err = a.applyObjects(comment, ros, wave); err != nil {
+			return err
+		}
+		if comment, err = a.waitForObjects(comment, ros, wave, a.targetPause(ro.Path)); err != nil {
+			return err
+		}
+	}
+
+	// Exercise the new color before it takes traffic
+	if err := a.smokeTest(comment, ro, ros); err != nil {
+		return err
+	}
+
+	// Switch the Services to the new color
+	var err error
+	if comment, err = a.applyObjects(comment, ros, services); err != nil {
+		return err
+	}
+	if comment, err = a.waitForObjects(comment, ros, services, a.targetPause(ro.Path)); err != nil {
+		return err
+	}
+
+	// Scale down the retired color, leaving it in place for a quick rollback
+	for _, d := range retired {
+		log.Printf("scaling down %s/%s\n", d.namespace, d.name)
+		args := []string{"scale", "--replicas=0", "deployment/" + d.name}
+		if d.namespace != "" {
+			args = append(args, "-n", d.namespace)
+		}
+		if out, err := a.kubectl(args...).CombinedOutput(); err != nil {
+			log.Printf("could not scale down %s/%s: %s\n", d.namespace, d.name, out)
+		}
+	}
+
+	if live != "" && live != next {
+		ro.BlueGreen = fmt.Sprintf("`%s` is live, `%s` scaled down", next, live)
+	} else {
+		ro.BlueGreen = fmt.Sprintf("`%s` is live", next)
+	}
+
+	ro.Status = "Complete"
+	ro.Icon = doneIcon
+	a.recordDuration(ro)
+
+	return nil
+}
+
+// liveColor returns the color currently taking traffic - read from the live Service
+// selectors - and the color the new version will be rolled out as
+func (a *Applier) liveColor(ro *rollout.Rollout) (live, next string) {
+	next = "blue"
+	if a.K8sClient == nil {
+		return
+	}
+	for _, o := range ro.Objects {
+		if o.Kind != "Service" {
+			continue
+		}
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "Service"})
+		if err := a.K8sClient.Get(context.TODO(), o.NamespacedName, u); err != nil {
+			continue
+		}
+		selector, _, _ := unstructured.NestedStringMap(u.Object, "spec", "selector")
+		if c := selector[rollout.ColorLabel]; c != "" {
+			live = c
+			if c == "blue" {
+				next = "green"
+			}
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// checkCapacity compares the aggregate cpu and memory requests the target would add against
+// allocatable cluster capacity and the ResourceQuotas of the target's namespaces, returning
+// a finding per resource the rollout would exceed
+func (a *Applier) checkCapacity(ro *rollout.Rollout) ([]string, error) {
+	var findings []string
+
+	var cpu, memory resource.Quantity
+	namespaces := sets.NewString()
+	perNamespaceCPU := map[string]*resource.Quantity{}
+	perNamespaceMemory := map[string]*resource.Quantity{}
+	objects := append([]*rollout.Object{}, ro.Objects...)
+	objects = append(objects, ro.Hooks...)
+	for _, o := range objects {
+		c, m := o.Requests()
+		cpu.Add(c)
+		memory.Add(m)
+		if rollout.IsClusterScoped(o.Kind) {
+			continue
+		}
+		ns := o.Namespace
+		if namespaces.Has(ns) {
+			perNamespaceCPU[ns].Add(c)
+			perNamespaceMemory[ns].Add(m)
+			continue
+		}
+		namespaces.Insert(ns)
+		perNamespaceCPU[ns] = &c
+		perNamespaceMemory[ns] = &m
+	}
+	if cpu.IsZero() && memory.IsZero() {
+		return nil, nil
+	}
+
+	// Compare the totals against the cluster's allocatable capacity
+	nodes := &corev1.NodeList{}
+	if err := a.K8sClient.List(context.TODO(), &client.ListOptions{}, nodes); err != nil {
+		return nil, err
+	}
+	var allocatableCPU, allocatableMemory resource.Quantity
+	for i := range nodes.Items {
+		allocatableCPU.Add(nodes.Items[i].Status.Allocatable[corev1.ResourceCPU])
+		allocatableMemory.Add(nodes.Items[i].Status.Allocatable[corev1.ResourceMemory])
+	}
+	if cpu.Cmp(allocatableCPU) > 0 {
+		findings = append(findings, fmt.Sprintf(
+			"rollout requests %s cpu but the cluster has %s allocatable",
+			cpu.String(), allocatableCPU.String()))
+	}
+	if memory.Cmp(allocatableMemory) > 0 {
+		findings = append(findings, fmt.Sprintf(
+			"rollout requests %s memory but the cluster has %s allocatable",
+			memory.String(), allocatableMemory.String()))
+	}
+
+	// Compare the per-namespace totals against the namespace ResourceQuotas
+	for _, ns := range namespaces.List() {
+		quotas := &corev1.ResourceQuotaList{}
+		if err := a.K8sClient.List(context.TODO(), client.InNamespace(ns), quotas); err != nil {
+			return nil, err
+		}
+		for i := range quotas.Items {
+			q := &quotas.Items[i]
+			f := quotaFinding(q, corev1.ResourceRequestsCPU, corev1.ResourceCPU,
+				perNamespaceCPU[ns], "cpu")
+			findings = append(findings, f...)
+			f = quotaFinding(q, corev1.ResourceRequestsMemory, corev1.ResourceMemory,
+				perNamespaceMemory[ns], "memory")
+			findings = append(findings, f...)
+		}
+	}
+	return findings, nil
+}
+
+// quotaFinding returns a finding if adding the requests would exceed the quota's hard limit
+// for the resource
+func quotaFinding(q *corev1.ResourceQuota, requestsName, plainName corev1.ResourceName,
+	added *resource.Quantity, display string) []string {
+	name := requestsName
+	hard, found := q.Status.Hard[name]
+	if !found {
+		name = plainName
+		if hard, found = q.Status.Hard[name]; !found {
+			return nil
+		}
+	}
+	total := q.Status.Used[name]
+	total.Add(*added)
+	if total.Cmp(hard) <= 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf(
+		"rollout would use %s %s in namespace %s but quota %s allows %s",
+		total.String(), display, q.Namespace, q.Name, hard.String())}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TargetDurationsAnnotation persists the durations of past rollouts of each target on the
+// applier Deployment so duration history survives pod restarts
+const TargetDurationsAnnotation = "apply.k8s.io/target-durations"
+
+// maxDurationHistory bounds how many past rollout durations are kept per target
+const maxDurationHistory = 10
+
+// loadDurations restores per-target rollout duration history from the applier Deployment
+// annotation.  The Deployment is found by the apply.k8s.io/applier label set by the controller.
+func (a *Applier) loadDurations() map[string][]time.Duration {
+	durations := map[string][]time.Duration{}
+	if a.ProgressNamespace == "" || a.K8sClient == nil {
+		return durations
+	}
+
+	deployments := &appsv1.DeploymentList{}
+	opts := client.InNamespace(a.ProgressNamespace).
+		MatchingLabels(map[string]string{"apply.k8s.io/applier": a.Name})
+	if err := a.K8sClient.List(context.TODO(), opts, deployments); err != nil {
+		log.Printf("failed to find applier deployment %v", err)
+		return durations
+	}
+
+	for i := range deployments.Items {
+		raw := deployments.Items[i].Annotations[TargetDurationsAnnotation]
+		if raw == "" {
+			continue
+		}
+		serialized := map[string][]string{}
+		if err := json.Unmarshal([]byte(raw), &serialized); err != nil {
+			log.Printf("failed to parse duration history %v", err)
+			continue
+		}
+		for path, ds := range serialized {
+			for _, s := range ds {
+				d, err := time.ParseDuration(s)
+				if err != nil {
+					continue
+				}
+				durations[path] = append(durations[path], d)
+			}
+		}
+		break
+	}
+	return durations
+}
+
+// saveDurations persists per-target rollout duration history to the applier Deployment
+// annotation
+func (a *Applier) saveDurations() {
+	if a.ProgressNamespace == "" || a.K8sClient == nil {
+		return
+	}
+
+	serialized := map[string][]string{}
+	for path, ds := range a.targetDurations {
+		for _, d := range ds {
+			serialized[path] = append(serialized[path], d.String())
+		}
+	}
+	b, err := json.Marshal(serialized)
+	if err != nil {
+		log.Printf("failed to serialize duration history %v", err)
+		return
+	}
+
+	deployments := &appsv1.DeploymentList{}
+	opts := client.InNamespace(a.ProgressNamespace).
+		MatchingLabels(map[string]string{"apply.k8s.io/applier": a.Name})
+	if err := a.K8sClient.List(context.TODO(), opts, deployments); err != nil {
+		log.Printf("failed to find applier deployment %v", err)
+		return
+	}
+
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		if d.Annotations == nil {
+			d.Annotations = map[string]string{}
+		}
+		d.Annotations[TargetDurationsAnnotation] = string(b)
+		if err := a.K8sClient.Update(context.TODO(), d); err != nil {
+			log.Printf("failed to update duration history %v", err)
+		}
+	}
+}
+
+// medianDuration is the trailing median of the target's past rollout durations.  Zero if the
+// target has too little history to be meaningful.
+func (a *Applier) medianDuration(path string) time.Duration {
+	durations := a.targetDurations[path]
+	if len(durations) < 3 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// lastAppliedAnnotation records the config an object was last applied from, mirroring
+// kubectl so embedded and kubectl applies can take over from each other
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// embeddedApply applies the object through the API in-process with kubectl apply
+// semantics: changes are computed as a JSON merge patch between the previously applied
+// config and the rendered one, so fields removed from config are removed from the cluster
+// without clobbering fields owned by controllers.  Returns a kubectl-style status message.
+func (a *Applier) embeddedApply(o *rollout.Object) (string, error) {
+	rc, err := a.resourceClient(o)
+	if err != nil {
+		return "", err
+	}
+
+	desired := map[string]interface{}{}
+	if err := json.Unmarshal(o.Raw, &desired); err != nil {
+		return "", fmt.Errorf("could not parse %s: %v", o.Display(), err)
+	}
+	setAnnotation(desired, lastAppliedAnnotation, string(o.Raw))
+
+	live, err := rc.Get(o.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if a.DryRun != "" {
+			return o.Display() + " created (dry run)", nil
+		}
+		if _, err := rc.Create(&unstructured.Unstructured{Object: desired}); err != nil {
+			return "", err
+		}
+		return o.Display() + " created", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	patch := mergePatch(lastApplied(live), desired)
+	if len(patch) == 0 {
+		return o.Display() + " unchanged", nil
+	}
+	if a.DryRun != "" {
+		return o.Display() + " configured (dry run)", nil
+	}
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return "", err
+	}
+	if _, err := rc.Patch(o.Name, types.MergePatchType, b); err != nil {
+		return "", err
+	}
+	return o.Display() + " configured", nil
+}
+
+// embeddedUnchanged returns true if applying the object in-process would be a no-op
+func (a *Applier) embeddedUnchanged(o *rollout.Object) bool {
+	rc, err := a.resourceClient(o)
+	if err != nil {
+		return false
+	}
+	desired := map[string]interface{}{}
+	if err := json.Unmarshal(o.Raw, &desired); err != nil {
+		return false
+	}
+	setAnnotation(desired, lastAppliedAnnotation, string(o.Raw))
+
+	live, err := rc.Get(o.Name, metav1.GetOptions{})
+	if err != nil {
+		return false
+	}
+	return len(mergePatch(lastApplied(live), desired)) == 0
+}
+
+// resourceClient returns the dynamic client for the object's resource and namespace
+func (a *Applier) resourceClient(o *rollout.Object) (dynamic.ResourceInterface, error) {
+	c, err := a.DynamicClient.ClientForGroupVersionKind(o.GroupVersionKind)
+	if err != nil {
+		return nil, err
+	}
+	res := &metav1.APIResource{
+		Name:       rollout.ResourceFor(o.Kind),
+		Kind:       o.Kind,
+		Namespaced: !rollout.IsClusterScoped(o.Kind),
+	}
+	return c.Resource(res, o.Namespace), nil
+}
+
+// lastApplied returns the config the live object was last applied from.  Objects never
+// applied before merge against an empty config, so every rendered field is patched.
+func lastApplied(live *unstructured.Unstructured) map[string]interface{} {
+	previous := map[string]interface{}{}
+	if raw, found := live.GetAnnotations()[lastAppliedAnnotation]; found {
+		_ = json.Unmarshal([]byte(raw), &previous)
+	}
+	return previous
+}
+
+// mergePatch computes the JSON merge patch that takes the previously applied config to the
+// rendered one.  Fields present before but no longer rendered are set null so the server
+// removes them; fields the config never set are left untouched.
+func mergePatch(original, modified map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for k, m := range modified {
+		o, found := original[k]
+		if !found {
+			patch[k] = m
+			continue
+		}
+		om, oOK := o.(map[string]interface{})
+		mm, mOK := m.(map[string]interface{})
+		if oOK && mOK {
+			if sub := mergePatch(om, mm); len(sub) > 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !reflect.DeepEqual(o, m) {
+			patch[k] = m
+		}
+	}
+	for k := range original {
+		if _, found := modified[k]; !found {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
+
+// setAnnotation sets the annotation in parsed config, creating the metadata maps if needed
+func setAnnotation(obj map[string]interface{}, key, value string) {
+	meta, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		meta = map[string]interface{}{}
+		obj["metadata"] = meta
+	}
+	annotations, ok := meta["annotations"].(map[string]interface{})
+	if !ok {
+		annotations = map[string]interface{}{}
+		meta["annotations"] = annotations
+	}
+	annotations[key] = value
+}
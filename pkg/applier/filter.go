@@ -0,0 +1,76 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// filterObjects drops rendered objects the target's label selector or kind exclusions
+// reject, so one kustomization can be split across appliers with different clusters and
+// permissions
+func (a *Applier) filterObjects(path string, ro *rollout.Rollout) error {
+	selector := a.TargetSelectors[path]
+	excluded := a.TargetExcludeKinds[path]
+	if selector == "" && len(excluded) == 0 {
+		return nil
+	}
+
+	var sel labels.Selector
+	if selector != "" {
+		var err error
+		if sel, err = labels.Parse(selector); err != nil {
+			return fmt.Errorf("could not parse selector for %s: %v", path, err)
+		}
+	}
+
+	keep := func(o *rollout.Object) bool {
+		for _, k := range excluded {
+			if k == o.Kind || k == o.Group+"/"+o.Kind {
+				return false
+			}
+		}
+		return sel == nil || sel.Matches(labels.Set(o.Labels()))
+	}
+
+	dropped := 0
+	var objects, hooks []*rollout.Object
+	for _, o := range ro.Objects {
+		if keep(o) {
+			objects = append(objects, o)
+		} else {
+			dropped++
+		}
+	}
+	for _, o := range ro.Hooks {
+		if keep(o) {
+			hooks = append(hooks, o)
+		} else {
+			dropped++
+		}
+	}
+	ro.Objects, ro.Hooks = objects, hooks
+
+	if dropped > 0 {
+		log.Printf("filtered %d rendered objects out of %s\n", dropped, path)
+	}
+	return nil
+}
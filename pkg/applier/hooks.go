@@ -0,0 +1,122 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+)
+
+// runHooks applies the hook objects in ro annotated with the given phase and waits for each
+// to complete.  Hook objects are deleted afterwards according to their delete policy.
+func (a *Applier) runHooks(comment *github.IssueComment, ro *rollout.Rollout, ros *rollout.Rollouts, phase string) error {
+	for _, o := range ro.Hooks {
+		if o.Hook != phase {
+			continue
+		}
+		log.Printf("running %s hook %s\n", phase, o.Display())
+
+		// Delete any hook object left over from a previous rollout before re-creating it
+		if o.HookDeletePolicy == rollout.HookDeleteBeforeCreation {
+			_ = a.deleteObject(o)
+		}
+
+		// Apply the hook object
+		cmd := a.kubectlApply()
+		cmd.Stdin = bytes.NewBuffer(o.Raw)
+		out, err := cmd.CombinedOutput()
+		log.Printf("%s", out)
+		o.ApplyStatus = strings.TrimSpace(string(out))
+		a.auditLog("apply", o, err)
+		if err != nil {
+			_, _ = a.updateComment(comment, ros)
+			return fmt.Errorf("%v error applying %s hook %s", err, phase, o.Raw)
+		}
+		if comment, err = a.updateComment(comment, ros); err != nil {
+			return err
+		}
+
+		// Wait for the hook to complete
+		for !o.Done {
+			status, d, err := a.hookStatus(o)
+			status = strings.TrimSpace(status)
+			o.Done = d
+
+			if err != nil {
+				o.RolloutStatus = fmt.Sprintf("error: %v", err)
+				_, _ = a.updateComment(comment, ros)
+				if o.HookDeletePolicy == rollout.HookDeleteFailed {
+					_ = a.deleteObject(o)
+				}
+				return fmt.Errorf("'%v' error running %s hook %s", err, phase, o.Display())
+			}
+
+			if o.RolloutStatus != status {
+				log.Println(status)
+				o.RolloutStatus = status
+				o.RolloutStatusHistory = append(o.RolloutStatusHistory, fmt.Sprintf("*%s* - `%s`", time.Now().Format(time.RFC822), status))
+				if comment, err = a.updateComment(comment, ros); err != nil {
+					return err
+				}
+			}
+
+			// Pause between checking status
+			if !d {
+				time.Sleep(a.Pause)
+			}
+		}
+
+		if o.HookDeletePolicy == rollout.HookDeleteSucceeded {
+			_ = a.deleteObject(o)
+		}
+	}
+	return nil
+}
+
+// hookStatus returns a message describing the hook object status, and a bool value indicating
+// if the hook is considered finished.
+func (a *Applier) hookStatus(o *rollout.Object) (string, bool, error) {
+	if a.DryRun != "" {
+		// Nothing was persisted, so there is no hook status to wait for
+		return "skipped (dry-run)", true, nil
+	}
+	viewer := rollout.GetStatusViewer(o.Object, a.K8sClient)
+	if viewer == nil {
+		// Hooks without a status viewer are considered finished once they have been applied
+		return "applied", true, nil
+	}
+	return viewer.Status(o.NamespacedName, o.Revision)
+}
+
+// deleteObject deletes the object from the cluster
+func (a *Applier) deleteObject(o *rollout.Object) error {
+	if a.DryRun != "" {
+		return nil
+	}
+	cmd := a.kubectl("delete", "--ignore-not-found", "-f", "-")
+	cmd.Stdin = bytes.NewBuffer(o.Raw)
+	out, err := cmd.CombinedOutput()
+	log.Printf("%s", out)
+	a.auditLog("delete", o, err)
+	return err
+}
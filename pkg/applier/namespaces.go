@@ -0,0 +1,80 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"context"
+	"log"
+
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ensureNamespaces creates any namespaces referenced by the target's objects that don't
+// exist yet instead of failing the apply.  Created namespaces are labeled with the tracking
+// labels and recorded on the Rollout so they are listed in the comment.
+func (a *Applier) ensureNamespaces(ro *rollout.Rollout) error {
+	if !a.CreateNamespaces || a.K8sClient == nil {
+		return nil
+	}
+
+	namespaces := sets.NewString()
+	for _, o := range ro.Objects {
+		if !rollout.IsClusterScoped(o.Kind) {
+			namespaces.Insert(o.Namespace)
+		}
+	}
+	for _, o := range ro.Hooks {
+		if !rollout.IsClusterScoped(o.Kind) {
+			namespaces.Insert(o.Namespace)
+		}
+	}
+
+	for _, ns := range namespaces.List() {
+		err := a.K8sClient.Get(context.TODO(), types.NamespacedName{Name: ns}, &corev1.Namespace{})
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if a.DryRun != "" {
+			log.Printf("dry-run: create namespace %s\n", ns)
+			ro.CreatedNamespaces = append(ro.CreatedNamespaces, ns)
+			continue
+		}
+		n := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: ns,
+				Labels: map[string]string{
+					rollout.AppliedByLabel:     a.Name,
+					rollout.AppliedCommitLabel: a.GitClient.Commit,
+				},
+			},
+		}
+		if err := a.K8sClient.Create(context.TODO(), n); err != nil {
+			return err
+		}
+		log.Printf("created namespace %s\n", ns)
+		ro.CreatedNamespaces = append(ro.CreatedNamespaces, ns)
+	}
+	return nil
+}
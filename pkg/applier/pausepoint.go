@@ -0,0 +1,110 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+)
+
+var continueRegex = regexp.MustCompile(`(?m)^/continue\s*$`)
+
+// continueLabel releases a pause point when added to the rollout issue, as an
+// alternative to the /continue comment.  It is removed when the rollout resumes so it
+// only releases one pause point.
+const continueLabel = "continue"
+
+// pausePoint blocks after the target completes until a repo collaborator comments
+// /continue - or adds the continue label - on the rollout issue, enabling manual
+// verification steps in the middle of a multi-target rollout
+func (a *Applier) pausePoint(comment *github.IssueComment, ro *rollout.Rollout, ros *rollout.Rollouts) error {
+	if !a.TargetPauseAfter[ro.Path] || a.DryRun != "" {
+		return nil
+	}
+
+	log.Printf("pausing after %s until /continue\n", ro.Path)
+	since := time.Now()
+	prev := ro.Status
+	for {
+		if err := a.canceled(); err != nil {
+			return err
+		}
+
+		resume, err := a.continueRequested(since)
+		if err != nil {
+			log.Printf("could not check for /continue: %v", err)
+		}
+		if resume {
+			break
+		}
+
+		ro.Status = fmt.Sprintf("Paused for verification - comment `/continue` to resume "+
+			"(waiting %s)...", time.Since(since).Round(time.Minute))
+		if comment, err = a.updateComment(comment, ros); err != nil {
+			return err
+		}
+		time.Sleep(30 * time.Second)
+	}
+
+	ro.Status = prev
+	_, err := a.updateComment(comment, ros)
+	return err
+}
+
+// continueRequested returns true if a repo collaborator commented /continue on the
+// rollout issue after the pause started, or added the continue label.  The label is
+// removed so it only releases this pause point.
+func (a *Applier) continueRequested(since time.Time) (bool, error) {
+	issue, _, err := a.GitClient.Issues.Get(context.TODO(), a.GitClient.Owner, a.GitClient.Repo, a.IssueNum)
+	if err != nil {
+		return false, err
+	}
+	for _, l := range issue.Labels {
+		if l.GetName() == continueLabel {
+			if err := a.GitClient.RemoveLabels(a.IssueNum, continueLabel); err != nil {
+				log.Printf("%v", err)
+			}
+			return true, nil
+		}
+	}
+
+	comments, _, err := a.GitClient.Issues.ListComments(context.TODO(), a.GitClient.Owner,
+		a.GitClient.Repo, a.IssueNum, nil)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range comments {
+		if c.GetCreatedAt().Before(since) || !continueRegex.MatchString(c.GetBody()) {
+			continue
+		}
+		collaborator, _, err := a.GitClient.Repositories.IsCollaborator(context.TODO(),
+			a.GitClient.Owner, a.GitClient.Repo, c.User.GetLogin())
+		if err != nil {
+			return false, err
+		}
+		if collaborator {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// preflightViolations server-side dry-runs each of the rollout's objects so the
+// apiserver's full admission chain - including the namespace's Pod Security level -
+// judges them before anything is applied.  Returns the display name of each refused
+// object, with the admission error recorded in its ApplyStatus.
+func (a *Applier) preflightViolations(ro *rollout.Rollout) []string {
+	var violations []string
+	for _, o := range append(ro.Objects, ro.Hooks...) {
+		cmd := a.kubectl("apply", "-f", "-", "--server-dry-run")
+		cmd.Stdin = bytes.NewBuffer(o.Raw)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			o.ApplyStatus = "refused: " + strings.TrimSpace(string(out))
+			violations = append(violations, o.Display())
+		}
+	}
+	return violations
+}
+
+// enforcedLevels reports each target namespace's enforced Pod Security level so readers
+// can see what the preflight violations were judged against.  Namespaces without an
+// enforce label are omitted.
+func (a *Applier) enforcedLevels(ro *rollout.Rollout) []string {
+	if a.K8sClient == nil {
+		return nil
+	}
+
+	namespaces := sets.NewString()
+	for _, o := range append(ro.Objects, ro.Hooks...) {
+		if rollout.IsClusterScoped(o.Kind) {
+			continue
+		}
+		namespaces.Insert(o.Namespace)
+	}
+
+	var levels []string
+	for _, ns := range namespaces.List() {
+		namespace := &corev1.Namespace{}
+		if err := a.K8sClient.Get(context.TODO(), types.NamespacedName{Name: ns}, namespace); err != nil {
+			continue
+		}
+		if level := namespace.Labels["pod-security.kubernetes.io/enforce"]; level != "" {
+			levels = append(levels, fmt.Sprintf("namespace %q enforces Pod Security level %q", ns, level))
+		}
+	}
+	return levels
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CurrentCommitAnnotation is the commit currently being rolled out
+	CurrentCommitAnnotation = "apply.k8s.io/current-commit"
+
+	// RolloutStateAnnotation is the state of the rollout of the current commit
+	RolloutStateAnnotation = "apply.k8s.io/rollout-state"
+
+	// IssueAnnotation is the GitHub issue number tracking the rollout
+	IssueAnnotation = "apply.k8s.io/issue"
+)
+
+// setProgress annotates the applier Deployment with the current commit, rollout state, and
+// issue number so cluster-side dashboards can track progress without GitHub access.  The
+// Deployment is found by the apply.k8s.io/applier label set by the controller.
+func (a *Applier) setProgress(state string) {
+	// Mirror the transition to the embedding caller's sink
+	if a.Status != nil {
+		a.Status.SetState(state)
+	}
+
+	if a.ProgressNamespace == "" || a.K8sClient == nil {
+		return
+	}
+
+	deployments := &appsv1.DeploymentList{}
+	opts := client.InNamespace(a.ProgressNamespace).
+		MatchingLabels(map[string]string{"apply.k8s.io/applier": a.Name})
+	if err := a.K8sClient.List(context.TODO(), opts, deployments); err != nil {
+		log.Printf("failed to find applier deployment %v", err)
+		return
+	}
+
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		if d.Annotations == nil {
+			d.Annotations = map[string]string{}
+		}
+		d.Annotations[CurrentCommitAnnotation] = a.GitClient.Commit
+		d.Annotations[RolloutStateAnnotation] = state
+		d.Annotations[IssueAnnotation] = strconv.Itoa(a.IssueNum)
+		if err := a.K8sClient.Update(context.TODO(), d); err != nil {
+			log.Printf("failed to update progress annotations %v", err)
+		}
+	}
+}
+
+// setStatusConfigMap mirrors the rendered rollout status comment into a ConfigMap so
+// in-cluster users can read deploy state with kubectl in clusters without outbound GitHub
+// access.  It is updated on the same cadence as the comment.
+func (a *Applier) setStatusConfigMap(body string) {
+	if a.ProgressNamespace == "" || a.K8sClient == nil {
+		return
+	}
+
+	name := types.NamespacedName{Namespace: a.ProgressNamespace, Name: "rollout-status-" + a.Name}
+	data := map[string]string{
+		"status": body,
+		"commit": a.GitClient.Commit,
+		"issue":  strconv.Itoa(a.IssueNum),
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := a.K8sClient.Get(context.TODO(), name, cm)
+	if errors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: name.Namespace,
+				Name:      name.Name,
+				Labels:    map[string]string{"apply.k8s.io/applier": a.Name},
+			},
+			Data: data,
+		}
+		if err := a.K8sClient.Create(context.TODO(), cm); err != nil {
+			log.Printf("failed to create status configmap %v", err)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("failed to get status configmap %v", err)
+		return
+	}
+	cm.Data = data
+	if err := a.K8sClient.Update(context.TODO(), cm); err != nil {
+		log.Printf("failed to update status configmap %v", err)
+	}
+}
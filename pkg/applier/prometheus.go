@@ -0,0 +1,88 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+)
+
+// readinessGate holds an otherwise-done object until its readiness query has evaluated
+// true for the annotated duration, letting teams define app-level readiness beyond
+// Kubernetes status.  Returns the gated status message and whether the object is done.
+func (a *Applier) readinessGate(o *rollout.Object, status string) (string, bool) {
+	ok, err := a.queryTrue(o.ReadinessQuery)
+	if err != nil {
+		o.ReadySince = time.Time{}
+		return fmt.Sprintf("readiness query failed: %v", err), false
+	}
+	if !ok {
+		o.ReadySince = time.Time{}
+		return "Waiting for the readiness query to be true...", false
+	}
+	if o.ReadySince.IsZero() {
+		o.ReadySince = time.Now()
+	}
+	if held := time.Since(o.ReadySince); held < o.ReadinessFor {
+		return fmt.Sprintf("Waiting for the readiness query to hold (%s of %s)...",
+			held.Round(time.Second), o.ReadinessFor), false
+	}
+	return strings.TrimSpace(status) + " (readiness query true)", true
+}
+
+// queryTrue evaluates the PromQL expression against the configured Prometheus, returning
+// true if it yields at least one sample with a non-zero value
+func (a *Applier) queryTrue(query string) (bool, error) {
+	u := fmt.Sprintf("%s/api/v1/query?query=%s",
+		strings.TrimSuffix(a.PrometheusURL, "/"), url.QueryEscape(query))
+	resp, err := http.Get(u)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("prometheus returned %s", resp.Status)
+	}
+
+	result := struct {
+		Status string `json:"status"`
+		Data   struct {
+			Result []struct {
+				Value []interface{} `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	if result.Status != "success" {
+		return false, fmt.Errorf("prometheus query status %s", result.Status)
+	}
+
+	for _, r := range result.Data.Result {
+		if len(r.Value) == 2 && fmt.Sprintf("%v", r.Value[1]) != "0" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
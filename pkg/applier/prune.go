@@ -0,0 +1,127 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pwittrock/continuous-apply/pkg/audit"
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// pruneObjects deletes objects this applier stamped in earlier commits that are no longer
+// rendered, found by the tracking labels.  Deletions are waited on so the rollout is not
+// declared complete while dependents are still terminating.
+func (a *Applier) pruneObjects(ros *rollout.Rollouts) error {
+	if !a.Prune || a.DryRun != "" || a.DynamicClient == nil {
+		return nil
+	}
+
+	selector := fmt.Sprintf("%s=%s,%s!=%s",
+		rollout.AppliedByLabel, a.Name, rollout.AppliedCommitLabel, a.GitClient.Commit)
+
+	// Prune only the kinds rendered by this rollout so the applier never deletes kinds it
+	// does not manage
+	kinds := map[schema.GroupVersionKind]bool{}
+	for _, ro := range ros.Rollouts {
+		for _, o := range ro.Objects {
+			kinds[o.GroupVersionKind] = true
+		}
+		for _, o := range ro.Hooks {
+			kinds[o.GroupVersionKind] = true
+		}
+	}
+
+	for gvk := range kinds {
+		c, err := a.DynamicClient.ClientForGroupVersionKind(gvk)
+		if err != nil {
+			return err
+		}
+		res := &metav1.APIResource{
+			Name:       rollout.ResourceFor(gvk.Kind),
+			Kind:       gvk.Kind,
+			Namespaced: !rollout.IsClusterScoped(gvk.Kind),
+		}
+
+		list, err := c.Resource(res, metav1.NamespaceAll).List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return err
+		}
+		ul, ok := list.(*unstructured.UnstructuredList)
+		if !ok {
+			continue
+		}
+
+		for i := range ul.Items {
+			item := &ul.Items[i]
+			log.Printf("pruning %s %s/%s\n", gvk.Kind, item.GetNamespace(), item.GetName())
+			err := c.Resource(res, item.GetNamespace()).Delete(item.GetName(), a.deleteOptions(gvk.Kind))
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			a.Audit.Log(audit.Entry{
+				Actor:   a.User,
+				Action:  "prune",
+				Object:  fmt.Sprintf("%s \"%s/%s\"", gvk.Kind, item.GetNamespace(), item.GetName()),
+				Commit:  a.GitClient.Commit,
+				Outcome: audit.Outcome(err),
+			})
+		}
+
+		// Wait for the deletions to finish.  Orphaning deletions return once the object is
+		// gone; foreground deletions block until its dependents are too.
+		for i := range ul.Items {
+			item := &ul.Items[i]
+			for {
+				_, err := c.Resource(res, item.GetNamespace()).Get(item.GetName(), metav1.GetOptions{})
+				if errors.IsNotFound(err) {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				time.Sleep(a.Pause)
+			}
+		}
+	}
+	return nil
+}
+
+// deleteOptions builds the DeleteOptions for the kind from the configured propagation
+// policy and grace period
+func (a *Applier) deleteOptions(kind string) *metav1.DeleteOptions {
+	opts := &metav1.DeleteOptions{}
+	policy := metav1.DeletePropagationBackground
+	switch a.PrunePropagation[kind] {
+	case "Foreground":
+		policy = metav1.DeletePropagationForeground
+	case "Orphan":
+		policy = metav1.DeletePropagationOrphan
+	}
+	opts.PropagationPolicy = &policy
+	if g, found := a.PruneGracePeriods[kind]; found {
+		grace := g
+		opts.GracePeriodSeconds = &grace
+	}
+	return opts
+}
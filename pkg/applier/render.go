@@ -0,0 +1,144 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Renderer renders a target path into serialized objects to apply.  Renderers share the
+// validation, sorting, and status plumbing of the Applier.
+type Renderer interface {
+	// Render renders the target at path into serialized yaml objects
+	Render(path string) ([]string, error)
+}
+
+// renderers is the registry of Renderers keyed by target renderer type
+var renderers = map[string]Renderer{}
+
+// RegisterRenderer registers a Renderer under the name so targets can select it.  Third
+// parties may register custom renderers before the Applier runs.
+func RegisterRenderer(name string, r Renderer) {
+	renderers[name] = r
+}
+
+func init() {
+	RegisterRenderer("kustomize", &KustomizeRenderer{})
+	RegisterRenderer("raw", &RawRenderer{})
+}
+
+// render renders the target with its configured renderer.  Targets default to kustomize.
+func (a *Applier) render(path string) ([]string, error) {
+	name := a.TargetRenderers[path]
+	if name == "" {
+		name = "kustomize"
+		if a.Embedded {
+			name = "raw"
+		}
+	}
+	r, found := renderers[name]
+	if !found {
+		return nil, fmt.Errorf("no renderer named %s for target %s", name, path)
+	}
+	if k, ok := r.(*KustomizeRenderer); ok {
+		k.Sandbox = a.RenderSandbox
+		k.Env = nil
+		// Inject git credentials so kustomize can fetch private remote bases in-cluster.
+		// Sandboxed renders are left without them - an exec plugin could read them.
+		if a.GitClient != nil && a.RenderSandbox == "" {
+			env, err := a.GitClient.AskPassEnv()
+			if err != nil {
+				log.Printf("could not build kustomize credentials: %v", err)
+			} else {
+				k.Env = env
+			}
+		}
+	}
+	return r.Render(path)
+}
+
+// renderHash hashes rendered output so two renders of a target can be compared for
+// determinism
+func renderHash(objects []string) string {
+	h := sha256.New()
+	for _, o := range objects {
+		_, _ = io.WriteString(h, o)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// KustomizeRenderer renders targets with kustomize build
+type KustomizeRenderer struct {
+	// Env is extra environment for the kustomize process - e.g. git credentials for
+	// private remote bases
+	Env []string
+
+	// Sandbox is a wrapper command the build is exec'd through, with credential material
+	// stripped from the environment.  Empty execs kustomize directly.
+	Sandbox string
+}
+
+func (r *KustomizeRenderer) Render(path string) ([]string, error) {
+	cmd := exec.Command("kustomize", "build", path)
+	if len(r.Env) > 0 {
+		cmd.Env = append(os.Environ(), r.Env...)
+	}
+	if r.Sandbox != "" {
+		cmd = exec.Command("sh", "-c", r.Sandbox+" kustomize build "+path)
+		cmd.Env = sandboxEnv()
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("failed to kustomize %s\n", out)
+		return nil, err
+	}
+	return strings.Split(string(out), "---\n"), nil
+}
+
+// RawRenderer renders the plain yaml files in the target directory without templating
+type RawRenderer struct{}
+
+func (r *RawRenderer) Render(path string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(path, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	yml, err := filepath.Glob(filepath.Join(path, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, yml...)
+
+	var objects []string
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, strings.Split(string(b), "---\n")...)
+	}
+	return objects, nil
+}
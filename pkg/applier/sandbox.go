@@ -0,0 +1,38 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"os"
+	"strings"
+)
+
+// sandboxEnv returns the render environment with credential material removed.  The
+// service account token and filesystem are restricted by the sandbox command itself;
+// this keeps exec plugins from reading tokens out of the environment.
+func sandboxEnv() []string {
+	var env []string
+	for _, e := range os.Environ() {
+		switch strings.SplitN(e, "=", 2)[0] {
+		case "GIT_ACCESS_TOKEN", "GIT_ACCESS_TOKEN_FILE", "GIT_ACCESS_TOKEN_COMMAND",
+			"KUBECONFIG", "GIT_ASKPASS":
+			continue
+		}
+		env = append(env, e)
+	}
+	return env
+}
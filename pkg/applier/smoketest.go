@@ -0,0 +1,49 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+)
+
+// smokeTest runs the smoke test suite against the target's freshly rolled-out workloads -
+// typically a test Job or HTTP probes - recording pass/fail in the comment.  A failing
+// suite fails the rollout, which runs the target's sync-fail hooks so teams can trigger a
+// rollback.
+func (a *Applier) smokeTest(comment *github.IssueComment, ro *rollout.Rollout, ros *rollout.Rollouts) error {
+	if a.SmokeTestCommand == "" || a.DryRun != "" {
+		return nil
+	}
+
+	log.Printf("running smoke tests against %s\n", ro.Path)
+	out, err := exec.Command("sh", "-c", a.SmokeTestCommand+" "+ro.Path).CombinedOutput()
+	if err != nil {
+		ro.SmokeTest = fmt.Sprintf("failed - `%s`", strings.TrimSpace(string(out)))
+		ro.Status = "Failed - smoke tests"
+		ros.Status = "Failed"
+		_, _ = a.updateComment(comment, ros)
+		return fmt.Errorf("smoke tests failed for %s: %v\n%s", ro.Path, err, out)
+	}
+	ro.SmokeTest = "passed"
+	return nil
+}
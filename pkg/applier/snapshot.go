@@ -0,0 +1,92 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// snapshotManifests stores the fully rendered manifests of the rollout in a gzipped
+// ConfigMap keyed by commit, so operators can later answer exactly what was applied for a
+// commit and diff two rollouts:
+//
+//	kubectl get configmap rendered-<commit> -o jsonpath='{.binaryData.manifests}' | base64 -d | gunzip
+func (a *Applier) snapshotManifests(ros *rollout.Rollouts) {
+	if a.SnapshotNamespace == "" || a.K8sClient == nil || a.DryRun != "" {
+		return
+	}
+
+	b := &bytes.Buffer{}
+	w := gzip.NewWriter(b)
+	for _, ro := range ros.Rollouts {
+		fmt.Fprintf(w, "# target: %s\n", ro.Path)
+		for _, o := range append(ro.Objects, ro.Hooks...) {
+			fmt.Fprintf(w, "---\n%s\n", o.JSON)
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("failed to compress manifest snapshot %v", err)
+		return
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshotName(a.GitClient.Commit),
+			Namespace: a.SnapshotNamespace,
+			Labels: map[string]string{
+				rollout.AppliedByLabel:     a.Name,
+				rollout.AppliedCommitLabel: a.GitClient.Commit,
+			},
+		},
+		BinaryData: map[string][]byte{"manifests": b.Bytes()},
+	}
+
+	err := a.K8sClient.Create(context.TODO(), cm)
+	if errors.IsAlreadyExists(err) {
+		// The rollout is a retry of a commit that was already snapshotted
+		existing := &corev1.ConfigMap{}
+		if err := a.K8sClient.Get(context.TODO(),
+			types.NamespacedName{Namespace: cm.Namespace, Name: cm.Name}, existing); err != nil {
+			log.Printf("failed to read manifest snapshot %v", err)
+			return
+		}
+		existing.Labels = cm.Labels
+		existing.BinaryData = cm.BinaryData
+		err = a.K8sClient.Update(context.TODO(), existing)
+	}
+	if err != nil {
+		log.Printf("failed to store manifest snapshot %v", err)
+		return
+	}
+	log.Printf("stored rendered manifest snapshot %s/%s\n", cm.Namespace, cm.Name)
+}
+
+// snapshotName is the ConfigMap name storing the commit's rendered manifests
+func snapshotName(commit string) string {
+	return "rendered-" + strings.ToLower(commit)
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"log"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+)
+
+// pushStatusBranch mirrors the rollout status onto the status branch as a structured YAML
+// file per applier, so git alone records deploy history without GitHub issue archaeology.
+// Pushes happen on rollout and target state transitions rather than every status update.
+func (a *Applier) pushStatusBranch(ros *rollout.Rollouts) {
+	if a.StatusBranch == "" || a.DryRun != "" {
+		return
+	}
+
+	fingerprint := ros.Status
+	for _, ro := range ros.Rollouts {
+		fingerprint += "|" + ro.Path + "=" + ro.Status
+	}
+	if fingerprint == a.lastStatusPush {
+		return
+	}
+
+	var finished time.Time
+	if ros.Status != "In Progress" {
+		finished = time.Now()
+	}
+	b, err := yaml.Marshal(rollout.Summarize(ros, a.GitClient.Commit, a.rolloutStarted, finished))
+	if err != nil {
+		log.Printf("failed to serialize status %v", err)
+		return
+	}
+	if err := a.GitClient.PushStatus(a.StatusBranch, a.Name+".yaml", b); err != nil {
+		log.Printf("failed to push status branch %v", err)
+		return
+	}
+	a.lastStatusPush = fingerprint
+}
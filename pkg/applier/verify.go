@@ -0,0 +1,97 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// verifyTarget verifies the provenance of the target's source files before they are
+// rendered, failing the rollout on mismatch so tampered repos or registries never reach
+// the cluster.
+func (a *Applier) verifyTarget(path string) error {
+	if want, found := a.TargetChecksums[path]; found {
+		got, err := checksumTarget(path)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(got, want) {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, sources hash to %s",
+				path, want, got)
+		}
+		log.Printf("verified checksum of %s\n", path)
+	}
+
+	if a.VerifyCommand != "" {
+		out, err := exec.Command("sh", "-c", a.VerifyCommand+" "+path).CombinedOutput()
+		if err != nil {
+			log.Printf("verification failed for %s: %s\n", path, out)
+			return fmt.Errorf("provenance verification failed for %s: %v", path, err)
+		}
+		log.Printf("verified provenance of %s\n", path)
+	}
+	return nil
+}
+
+// checksumTarget hashes the target's source files into a single sha256 hex digest.  Files
+// are hashed with their relative paths in sorted order so the digest is stable across
+// checkouts.
+func checksumTarget(path string) (string, error) {
+	var files []string
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, f := range files {
+		rel, err := filepath.Rel(path, f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n", rel)
+		in, err := os.Open(f)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(h, in); err != nil {
+			in.Close()
+			return "", err
+		}
+		in.Close()
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pwittrock/continuous-apply/pkg/rollout"
+)
+
+// window is a daily clock interval a target may roll out in, e.g. 02:00-04:00 in a
+// configured timezone
+type window struct {
+	// start and end are minutes of the day.  start > end wraps past midnight.
+	start, end int
+	loc        *time.Location
+}
+
+// parseWindow parses "HH:MM-HH:MM [IANA timezone]".  The timezone defaults to the
+// applier's local time.
+func parseWindow(s string) (*window, error) {
+	w := &window{loc: time.Local}
+	parts := strings.Fields(s)
+	if len(parts) == 2 {
+		var err error
+		if w.loc, err = time.LoadLocation(parts[1]); err != nil {
+			return nil, fmt.Errorf("unknown timezone %s: %v", parts[1], err)
+		}
+	}
+	if len(parts) == 0 || len(parts) > 2 {
+		return nil, fmt.Errorf("expected HH:MM-HH:MM [timezone], got %s", s)
+	}
+
+	times := strings.Split(parts[0], "-")
+	if len(times) != 2 {
+		return nil, fmt.Errorf("expected HH:MM-HH:MM [timezone], got %s", s)
+	}
+	var err error
+	if w.start, err = parseClock(times[0]); err != nil {
+		return nil, err
+	}
+	if w.end, err = parseClock(times[1]); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// parseClock parses HH:MM into minutes of the day
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %s", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// open returns true if now falls inside the window
+func (w *window) open(now time.Time) bool {
+	now = now.In(w.loc)
+	m := now.Hour()*60 + now.Minute()
+	if w.start <= w.end {
+		return m >= w.start && m < w.end
+	}
+	// The window wraps past midnight
+	return m >= w.start || m < w.end
+}
+
+// nextOpening returns when the window next opens
+func (w *window) nextOpening(now time.Time) time.Time {
+	now = now.In(w.loc)
+	opening := time.Date(now.Year(), now.Month(), now.Day(), w.start/60, w.start%60, 0, 0, w.loc)
+	if !opening.After(now) {
+		opening = opening.Add(24 * time.Hour)
+	}
+	return opening
+}
+
+// waitForWindow holds the target until its rollout window opens - e.g. database
+// migrations only between 02:00 and 04:00 local - showing the wait reason and a
+// countdown in the comment
+func (a *Applier) waitForWindow(comment *github.IssueComment, ro *rollout.Rollout, ros *rollout.Rollouts) error {
+	spec := a.TargetWindows[ro.Path]
+	if spec == "" || a.DryRun != "" {
+		return nil
+	}
+	w, err := parseWindow(spec)
+	if err != nil {
+		return fmt.Errorf("could not parse window for %s: %v", ro.Path, err)
+	}
+
+	prev := ro.Status
+	for !w.open(time.Now()) {
+		if err := a.canceled(); err != nil {
+			return err
+		}
+		remaining := w.nextOpening(time.Now()).Sub(time.Now())
+		ro.Status = fmt.Sprintf("Waiting for the %s rollout window to open (%s remaining)...",
+			spec, remaining.Round(time.Minute))
+		if comment, err = a.updateComment(comment, ros); err != nil {
+			return err
+		}
+
+		pause := time.Minute
+		if remaining < pause {
+			pause = remaining
+		}
+		// Don't spin on comment updates as the opening approaches
+		if pause < time.Second {
+			pause = time.Second
+		}
+		time.Sleep(pause)
+	}
+	ro.Status = prev
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applier
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	w, err := parseWindow("02:00-04:00")
+	if err != nil {
+		t.Fatalf("expected window to parse, got %v", err)
+	}
+	if w.start != 2*60 || w.end != 4*60 {
+		t.Errorf("expected 02:00-04:00, got %d-%d", w.start, w.end)
+	}
+
+	w, err = parseWindow("22:30-01:15 UTC")
+	if err != nil {
+		t.Fatalf("expected window with timezone to parse, got %v", err)
+	}
+	if w.start != 22*60+30 || w.end != 60+15 {
+		t.Errorf("expected 22:30-01:15, got %d-%d", w.start, w.end)
+	}
+	if w.loc != time.UTC {
+		t.Errorf("expected UTC, got %v", w.loc)
+	}
+
+	for _, bad := range []string{"", "02:00", "2pm-4pm", "02:00-04:00 Mars/Olympus", "02:00-04:00 a b"} {
+		if _, err := parseWindow(bad); err == nil {
+			t.Errorf("expected %q to fail to parse", bad)
+		}
+	}
+}
+
+func TestWindowOpen(t *testing.T) {
+	w, err := parseWindow("02:00-04:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.open(time.Date(2018, 1, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected 03:00 to be inside 02:00-04:00")
+	}
+	if w.open(time.Date(2018, 1, 1, 5, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected 05:00 to be outside 02:00-04:00")
+	}
+
+	// A window past midnight wraps
+	w, err = parseWindow("22:00-02:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.open(time.Date(2018, 1, 1, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected 23:00 to be inside 22:00-02:00")
+	}
+	if !w.open(time.Date(2018, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected 01:00 to be inside 22:00-02:00")
+	}
+	if w.open(time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected 12:00 to be outside 22:00-02:00")
+	}
+}
+
+func TestWindowNextOpening(t *testing.T) {
+	w, err := parseWindow("02:00-04:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2018, 1, 1, 1, 0, 0, 0, time.UTC)
+	if got := w.nextOpening(now); !got.Equal(time.Date(2018, 1, 1, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the window to open at 02:00 the same day, got %v", got)
+	}
+
+	now = time.Date(2018, 1, 1, 5, 0, 0, 0, time.UTC)
+	if got := w.nextOpening(now); !got.Equal(time.Date(2018, 1, 2, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the window to open at 02:00 the next day, got %v", got)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is a single append-only audit record of a cluster or GitHub mutation
+type Entry struct {
+	Time time.Time `json:"time"`
+
+	// Actor is the user the mutation was performed as
+	Actor string `json:"actor,omitempty"`
+
+	// Action is the mutation performed - e.g. "apply", "delete", "add-labels", "set-state"
+	Action string `json:"action"`
+
+	// Object identifies what was mutated - an object display name or issue number
+	Object string `json:"object,omitempty"`
+
+	// Commit is the commit being rolled out when the mutation was performed
+	Commit string `json:"commit,omitempty"`
+
+	// Hash is the sha256 of the applied config
+	Hash string `json:"hash,omitempty"`
+
+	// Outcome is "ok" or the error that occurred
+	Outcome string `json:"outcome"`
+}
+
+// Logger appends audit entries as JSON lines to a file.  A nil *Logger discards entries.
+type Logger struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLogger returns a Logger appending entries to the file at path
+func NewLogger(path string) *Logger {
+	return &Logger{path: path}
+}
+
+// Log appends the entry, stamping the time.  Failures to write are logged rather than failing
+// the mutation that was being recorded.
+func (l *Logger) Log(e Entry) {
+	if l == nil {
+		return
+	}
+	e.Time = time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("could not open audit log %v", err)
+		return
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(e); err != nil {
+		log.Printf("could not write audit log %v", err)
+	}
+}
+
+// Sha256 returns the hex sha256 of the config
+func Sha256(config []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(config))
+}
+
+// Outcome formats an error as an audit outcome
+func Outcome(err error) string {
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	return "ok"
+}
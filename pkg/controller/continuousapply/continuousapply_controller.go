@@ -19,6 +19,8 @@ package continuousapply
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
 	applyv1beta1 "github.com/pwittrock/continuous-apply/pkg/apis/apply/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
@@ -30,14 +32,19 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const caImage = "pwittrock/continuous-apply:v25"
 
+// maxConcurrentReconciles is the number of ContinuousApply objects reconciled in parallel
+const maxConcurrentReconciles = 4
+
 // Add creates a new ContinuousApply Controller and adds it to the Manager.  The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
@@ -52,13 +59,36 @@ func newReconciler(mgr manager.Manager) reconcile.Reconciler {
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	// Create a new controller
-	c, err := controller.New("continuousapply-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("continuousapply-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to ContinuousApply, skipping status-only updates by requiring the
+	// spec generation to change
+	generationChanged := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.MetaOld == nil || e.MetaNew == nil {
+				return true
+			}
+			return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration()
+		},
+	}
+	err = c.Watch(&source.Kind{Type: &applyv1beta1.ContinuousApply{}}, &handler.EnqueueRequestForObject{},
+		generationChanged)
 	if err != nil {
 		return err
 	}
 
-	// Watch for changes to ContinuousApply
-	err = c.Watch(&source.Kind{Type: &applyv1beta1.ContinuousApply{}}, &handler.EnqueueRequestForObject{})
+	// Watch the applier Deployments created for each ContinuousApply so drift from manual
+	// edits or deletions is detected and corrected
+	err = c.Watch(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestForOwner{
+		OwnerType:    &applyv1beta1.ContinuousApply{},
+		IsController: true,
+	})
 	if err != nil {
 		return err
 	}
@@ -74,6 +104,78 @@ type ReconcileContinuousApply struct {
 	scheme *runtime.Scheme
 }
 
+// requestInterval divides the GitHub API request budget of the bot account across the
+// appliers sharing the same GitCredentials so they collectively stay under the rate limit
+func (r *ReconcileContinuousApply) requestInterval(instance *applyv1beta1.ContinuousApply) (time.Duration, error) {
+	list := &applyv1beta1.ContinuousApplyList{}
+	if err := r.List(context.Background(), &client.ListOptions{}, list); err != nil {
+		return 0, err
+	}
+
+	sharing := 0
+	for _, ca := range list.Items {
+		if ca.Spec.Components.GitCredentials == instance.Spec.Components.GitCredentials {
+			sharing++
+		}
+	}
+	if sharing == 0 {
+		sharing = 1
+	}
+
+	perApplier := instance.Spec.GitHubRequestsPerHour / sharing
+	if perApplier < 1 {
+		perApplier = 1
+	}
+	return time.Hour / time.Duration(perApplier), nil
+}
+
+// quotaExhausted returns the reason the instance may not start another applier - the
+// namespace or repo applier quota is exhausted - or empty when it may proceed.  Only
+// instances that already have a Deployment count against the quotas, so queued instances
+// start in creation order as running ones finish.
+func (r *ReconcileContinuousApply) quotaExhausted(instance *applyv1beta1.ContinuousApply,
+	defaults NamespaceDefaults) (string, error) {
+	maxNamespace := MaxAppliersPerNamespace
+	if defaults.MaxAppliers > 0 {
+		maxNamespace = defaults.MaxAppliers
+	}
+	if maxNamespace == 0 && MaxAppliersPerRepo == 0 {
+		return "", nil
+	}
+
+	list := &applyv1beta1.ContinuousApplyList{}
+	if err := r.List(context.Background(), &client.ListOptions{}, list); err != nil {
+		return "", err
+	}
+
+	inNamespace, inRepo := 0, 0
+	for i := range list.Items {
+		ca := &list.Items[i]
+		if ca.Namespace == instance.Namespace && ca.Name == instance.Name {
+			continue
+		}
+		if ca.Status.Applier == nil {
+			continue
+		}
+		if ca.Namespace == instance.Namespace {
+			inNamespace++
+		}
+		if ca.Spec.Repo == instance.Spec.Repo {
+			inRepo++
+		}
+	}
+
+	if maxNamespace > 0 && inNamespace >= maxNamespace {
+		return fmt.Sprintf("namespace %s applier quota of %d exhausted",
+			instance.Namespace, maxNamespace), nil
+	}
+	if MaxAppliersPerRepo > 0 && inRepo >= MaxAppliersPerRepo {
+		return fmt.Sprintf("repo %s/%s applier quota of %d exhausted",
+			instance.Spec.Repo.Owner, instance.Spec.Repo.Repo, MaxAppliersPerRepo), nil
+	}
+	return "", nil
+}
+
 // Reconcile reads that state of the cluster for a ContinuousApply object and makes changes based on the state read
 // and what is in the ContinuousApply.Spec
 func (r *ReconcileContinuousApply) Reconcile(request reconcile.Request) (reconcile.Result, error) {
@@ -91,6 +193,40 @@ func (r *ReconcileContinuousApply) Reconcile(request reconcile.Request) (reconci
 		return reconcile.Result{}, err
 	}
 
+	// Skip objects outside the watched namespace when running namespace-scoped
+	if WatchNamespace != "" && instance.Namespace != WatchNamespace {
+		return reconcile.Result{}, nil
+	}
+
+	defaults := namespaceDefaults[instance.Namespace]
+	if !repoAllowed(defaults, instance) {
+		fmt.Printf("repo %s/%s is not allowed in namespace %s\n",
+			instance.Spec.Repo.Owner, instance.Spec.Repo.Repo, instance.Namespace)
+		return reconcile.Result{}, nil
+	}
+
+	// Queue the instance behind the applier quotas instead of creating its Deployment,
+	// recording why in status so tenants can see what their object is waiting on
+	if instance.Status.Applier == nil {
+		reason, err := r.quotaExhausted(instance, defaults)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if reason != "" {
+			fmt.Printf("%s: queueing %s/%s\n", reason, instance.Namespace, instance.Name)
+			if instance.Status.Reason != reason {
+				instance.Status.Reason = reason
+				if err := r.Update(context.Background(), instance); err != nil {
+					return reconcile.Result{}, err
+				}
+			}
+			// Requeue with the workqueue's backoff - this controller-runtime has no
+			// RequeueAfter - so the quota is re-checked as appliers finish
+			return reconcile.Result{Requeue: true}, nil
+		}
+		instance.Status.Reason = ""
+	}
+
 	applier := &appsv1.Deployment{}
 	spec := appsv1.DeploymentSpec{}
 	applier.Labels = map[string]string{"apply.k8s.io/applier": instance.Name}
@@ -105,6 +241,7 @@ func (r *ReconcileContinuousApply) Reconcile(request reconcile.Request) (reconci
 		"--repo", instance.Spec.Repo.Repo,
 		"--user", instance.Spec.User,
 		"--name", instance.Name,
+		"--progress-namespace", instance.Namespace,
 	}
 
 	if instance.Spec.Type != "" {
@@ -113,8 +250,198 @@ func (r *ReconcileContinuousApply) Reconcile(request reconcile.Request) (reconci
 	if instance.Spec.RolloutType != "" {
 		args = append(args, "--rollout-type", instance.Spec.RolloutType)
 	}
+	if instance.Spec.PublishSummary {
+		args = append(args, "--publish-summary")
+	}
+	if instance.Spec.ThreadComments {
+		args = append(args, "--thread-comments")
+	}
+	if instance.Spec.ObjectURLTemplate != "" {
+		args = append(args, "--object-url-template", instance.Spec.ObjectURLTemplate)
+	}
+	if instance.Spec.PrometheusURL != "" {
+		args = append(args, "--prometheus-url", instance.Spec.PrometheusURL)
+	}
+	if instance.Spec.AuditLog != "" {
+		args = append(args, "--audit-log", instance.Spec.AuditLog)
+	}
+	if instance.Spec.DryRun != "" {
+		args = append(args, "--dry-run", instance.Spec.DryRun)
+	}
+	if instance.Spec.Git.CommitterName != "" {
+		args = append(args, "--git-committer-name", instance.Spec.Git.CommitterName)
+	}
+	if instance.Spec.Git.CommitterEmail != "" {
+		args = append(args, "--git-committer-email", instance.Spec.Git.CommitterEmail)
+	}
+	if instance.Spec.Git.Proxy != "" {
+		args = append(args, "--proxy", instance.Spec.Git.Proxy)
+	}
+	if instance.Spec.Git.CABundle != "" {
+		args = append(args, "--ca-bundle", instance.Spec.Git.CABundle)
+	}
+	if instance.Spec.Git.APIEndpoint != "" {
+		args = append(args, "--github-endpoint", instance.Spec.Git.APIEndpoint)
+	}
+	if instance.Spec.Git.SyncStrategy != "" {
+		args = append(args, "--git-sync-strategy", instance.Spec.Git.SyncStrategy)
+	}
+	if instance.Spec.Git.Submodules {
+		args = append(args, "--git-submodules")
+	}
+	if instance.Spec.Git.LFS {
+		args = append(args, "--git-lfs")
+	}
+	if instance.Spec.Git.SparseCheckout {
+		args = append(args, "--sparse-checkout")
+	}
+	if instance.Spec.MaxFailures > 0 {
+		args = append(args, "--max-failures", fmt.Sprintf("%d", instance.Spec.MaxFailures))
+	}
+	if instance.Spec.FailureLabel != "" {
+		args = append(args, "--failure-label", instance.Spec.FailureLabel)
+	}
+	if instance.Spec.NotifyCommand != "" {
+		args = append(args, "--notify-command", instance.Spec.NotifyCommand)
+	}
+	if instance.Spec.VerifyCommand != "" {
+		args = append(args, "--verify-command", instance.Spec.VerifyCommand)
+	}
+	if instance.Spec.SmokeTestCommand != "" {
+		args = append(args, "--smoke-test-command", instance.Spec.SmokeTestCommand)
+	}
+	if instance.Spec.GitHubRequestsPerHour > 0 {
+		interval, err := r.requestInterval(instance)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		args = append(args, "--github-request-interval", interval.String())
+	}
+	if instance.Spec.PrioritizeReverts {
+		args = append(args, "--prioritize-reverts")
+	}
+	if instance.Spec.RevertsSkipGates {
+		args = append(args, "--reverts-skip-gates")
+	}
+	if instance.Spec.Freeze {
+		args = append(args, "--freeze")
+	}
+	if instance.Spec.FreezeLabel != "" {
+		args = append(args, "--freeze-label", instance.Spec.FreezeLabel)
+	}
+	if instance.Spec.PriorityLabel != "" {
+		args = append(args, "--priority-label", instance.Spec.PriorityLabel)
+	}
+	if instance.Spec.PreemptionPolicy != "" {
+		args = append(args, "--preemption-policy", instance.Spec.PreemptionPolicy)
+	}
+	if instance.Spec.HeartbeatIssue > 0 {
+		args = append(args, "--heartbeat-issue", fmt.Sprintf("%d", instance.Spec.HeartbeatIssue))
+	}
+	if instance.Spec.HeartbeatInterval != "" {
+		args = append(args, "--heartbeat-interval", instance.Spec.HeartbeatInterval)
+	}
+	if instance.Spec.QueueIssue > 0 {
+		args = append(args, "--queue-issue", fmt.Sprintf("%d", instance.Spec.QueueIssue))
+	}
+	if instance.Spec.NotifyPR {
+		args = append(args, "--notify-pr")
+	}
+	if instance.Spec.ApprovalTeam != "" {
+		args = append(args, "--approval-team", instance.Spec.ApprovalTeam)
+	}
+	if instance.Spec.ScanManifests {
+		args = append(args, "--scan-manifests")
+	}
+	if instance.Spec.ScanBlockSeverity != "" {
+		args = append(args, "--scan-block-severity", instance.Spec.ScanBlockSeverity)
+	}
+	if instance.Spec.PreflightAdmission {
+		args = append(args, "--preflight-admission")
+	}
+	if instance.Spec.CheckCapacity {
+		args = append(args, "--check-capacity")
+	}
+	if instance.Spec.BlockOnCapacity {
+		args = append(args, "--block-on-capacity")
+	}
+	if instance.Spec.SkipUnchanged {
+		args = append(args, "--skip-unchanged")
+	}
+	if instance.Spec.CheckDeterminism {
+		args = append(args, "--check-determinism")
+	}
+	if instance.Spec.DetectNoop {
+		args = append(args, "--detect-noop")
+	}
+	if instance.Spec.RenderSandbox != "" {
+		args = append(args, "--render-sandbox", instance.Spec.RenderSandbox)
+	}
+	if instance.Spec.Embedded {
+		args = append(args, "--embedded")
+	}
+	if instance.Spec.SnapshotManifests {
+		args = append(args, "--snapshot-namespace", instance.Namespace)
+	}
+	if instance.Spec.StatusBranch != "" {
+		args = append(args, "--status-branch", instance.Spec.StatusBranch)
+	}
+	for _, k := range sortedKeys(instance.Spec.StatusPlugins) {
+		args = append(args, "--status-plugins", fmt.Sprintf("%s=%s", k, instance.Spec.StatusPlugins[k]))
+	}
+	if instance.Spec.MaxParallelTargets > 0 {
+		args = append(args, "--max-parallel-targets", fmt.Sprintf("%d", instance.Spec.MaxParallelTargets))
+	}
+	if instance.Spec.CreateNamespaces {
+		args = append(args, "--create-namespaces")
+	}
+	if instance.Spec.Prune {
+		args = append(args, "--prune")
+	}
+	for _, k := range sortedKeys(instance.Spec.PrunePropagation) {
+		args = append(args, "--prune-propagation", fmt.Sprintf("%s=%s", k, instance.Spec.PrunePropagation[k]))
+	}
+	for _, k := range sortedKeysInt64(instance.Spec.PruneGracePeriods) {
+		args = append(args, "--prune-grace-periods", fmt.Sprintf("%s=%d", k, instance.Spec.PruneGracePeriods[k]))
+	}
+	for _, k := range instance.Spec.AllowedKinds {
+		args = append(args, "--allowed-kinds", k)
+	}
+	for _, k := range instance.Spec.DeniedKinds {
+		args = append(args, "--denied-kinds", k)
+	}
 	for _, t := range instance.Spec.Targets {
 		args = append(args, "--apply-targets", t.Path)
+		if t.RolloutType != "" {
+			args = append(args, "--target-rollout-types", fmt.Sprintf("%s=%s", t.Path, t.RolloutType))
+		}
+		if t.Pause != "" {
+			args = append(args, "--target-pauses", fmt.Sprintf("%s=%s", t.Path, t.Pause))
+		}
+		if t.Window != "" {
+			args = append(args, "--target-windows", fmt.Sprintf("%s=%s", t.Path, t.Window))
+		}
+		if t.PauseAfter {
+			args = append(args, "--pause-after", t.Path)
+		}
+		if t.Namespace != "" {
+			args = append(args, "--target-namespaces", fmt.Sprintf("%s=%s", t.Path, t.Namespace))
+		}
+		if t.Renderer != "" {
+			args = append(args, "--target-renderers", fmt.Sprintf("%s=%s", t.Path, t.Renderer))
+		}
+		if t.Checksum != "" {
+			args = append(args, "--target-checksums", fmt.Sprintf("%s=%s", t.Path, t.Checksum))
+		}
+		if t.DisallowPlaintextSecrets {
+			args = append(args, "--disallow-plaintext-secrets", t.Path)
+		}
+		if t.Selector != "" {
+			args = append(args, "--target-selectors", fmt.Sprintf("%s=%s", t.Path, t.Selector))
+		}
+		for _, k := range t.ExcludeKinds {
+			args = append(args, "--target-exclude-kinds", fmt.Sprintf("%s=%s", t.Path, k))
+		}
 	}
 
 	if instance.Spec.Match.Milestone != "" {
@@ -133,6 +460,9 @@ func (r *ReconcileContinuousApply) Reconcile(request reconcile.Request) (reconci
 	if instance.Spec.BeforeActions.SetState != "" {
 		args = append(args, "--before-set-state", instance.Spec.BeforeActions.SetState)
 	}
+	if instance.Spec.BeforeActions.SetMilestone != "" {
+		args = append(args, "--before-set-milestone", instance.Spec.BeforeActions.SetMilestone)
+	}
 	for _, a := range instance.Spec.BeforeActions.AddAssignees {
 		args = append(args, "--before-add-assignees", a)
 	}
@@ -149,6 +479,9 @@ func (r *ReconcileContinuousApply) Reconcile(request reconcile.Request) (reconci
 	if instance.Spec.AfterActions.SetState != "" {
 		args = append(args, "--after-set-state", instance.Spec.AfterActions.SetState)
 	}
+	if instance.Spec.AfterActions.SetMilestone != "" {
+		args = append(args, "--after-set-milestone", instance.Spec.AfterActions.SetMilestone)
+	}
 	for _, a := range instance.Spec.AfterActions.AddAssignees {
 		args = append(args, "--after-add-assignees", a)
 	}
@@ -162,6 +495,15 @@ func (r *ReconcileContinuousApply) Reconcile(request reconcile.Request) (reconci
 		args = append(args, "--after-remove-labels", l)
 	}
 
+	// Apply the namespace's default image and poll interval
+	image := caImage
+	if defaults.Image != "" {
+		image = defaults.Image
+	}
+	if defaults.PollInterval != "" {
+		args = append(args, "--pause", defaults.PollInterval)
+	}
+
 	automount := true
 	spec.Template.Spec.ServiceAccountName = instance.Spec.Components.ServiceAccount
 	spec.Template.Spec.AutomountServiceAccountToken = &automount
@@ -171,7 +513,7 @@ func (r *ReconcileContinuousApply) Reconcile(request reconcile.Request) (reconci
 		{Name: "applier",
 			Command: []string{"./continuous-apply"},
 			Args:    args,
-			Image:   caImage,
+			Image:   image,
 			Env: []corev1.EnvVar{
 				{Name: "GIT_ACCESS_TOKEN", ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
@@ -184,55 +526,211 @@ func (r *ReconcileContinuousApply) Reconcile(request reconcile.Request) (reconci
 		},
 	}
 
-	if instance.Spec.Components.Applier == nil {
-		// TODO: Search for Deployment using label selectors also in case the second write fails
-		// Create the applier object
-		applier.Spec = spec
-		applier.GenerateName = fmt.Sprintf("%s-", instance.Name)
-		applier.Namespace = instance.Namespace
-		if err := controllerutil.SetControllerReference(instance, applier, r.scheme); err != nil {
-			fmt.Printf("failed to set reference %v\n", err)
+	// Fetch short-lived tokens at runtime - e.g. from Vault or GCP Secret Manager -
+	// instead of referencing a Secret
+	if instance.Spec.Components.GitCredentials.TokenCommand != "" {
+		c := &spec.Template.Spec.Containers[0]
+		c.Env = []corev1.EnvVar{
+			{Name: "GIT_ACCESS_TOKEN_COMMAND",
+				Value: instance.Spec.Components.GitCredentials.TokenCommand},
+		}
+		if instance.Spec.Components.GitCredentials.TokenTTL != "" {
+			c.Env = append(c.Env, corev1.EnvVar{Name: "GIT_ACCESS_TOKEN_TTL",
+				Value: instance.Spec.Components.GitCredentials.TokenTTL})
+		}
+	}
+
+	// Project the token Secret as a file instead of an env var, keeping it out of kubectl
+	// describe output and crash dumps.  The applier re-reads the file on every request, so
+	// Secret rotations take effect without a restart.
+	if instance.Spec.Components.GitCredentials.Mount {
+		c := &spec.Template.Spec.Containers[0]
+		c.Env = []corev1.EnvVar{
+			{Name: "GIT_ACCESS_TOKEN_FILE",
+				Value: "/var/run/secrets/continuous-apply/" + instance.Spec.Components.GitCredentials.Key},
+		}
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      "git-credentials",
+			MountPath: "/var/run/secrets/continuous-apply",
+			ReadOnly:  true,
+		})
+		spec.Template.Spec.Volumes = append(spec.Template.Spec.Volumes, corev1.Volume{
+			Name: "git-credentials",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: instance.Spec.Components.GitCredentials.Secret.Name,
+				},
+			},
+		})
+	}
+
+	// Download pinned kubectl/kustomize versions for the node's architecture into a shared
+	// volume that shadows the tools baked into the image
+	if instance.Spec.Tools.Kubectl != "" || instance.Spec.Tools.Kustomize != "" {
+		spec.Template.Spec.Volumes = append(spec.Template.Spec.Volumes, corev1.Volume{
+			Name:         "tools",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		spec.Template.Spec.InitContainers = []corev1.Container{
+			{Name: "tools",
+				Command:      []string{"sh", "-c", toolsInitScript(instance.Spec.Tools)},
+				Image:        caImage,
+				VolumeMounts: []corev1.VolumeMount{{Name: "tools", MountPath: "/tools"}},
+			},
+		}
+		c := &spec.Template.Spec.Containers[0]
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: "tools", MountPath: "/tools"})
+		c.Env = append(c.Env, corev1.EnvVar{Name: "PATH",
+			Value: "/tools:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"})
+	}
+
+	// Migrate the reference from spec on objects written before it moved to status, so
+	// re-applying the CR from git no longer stomps it
+	if instance.Spec.Components.Applier != nil {
+		if instance.Status.Applier == nil {
+			instance.Status.Applier = instance.Spec.Components.Applier
+		}
+		instance.Spec.Components.Applier = nil
+		if err := r.Update(context.Background(), instance); err != nil {
+			fmt.Printf("failed to migrate applier reference %v\n", err)
 			return reconcile.Result{}, err
 		}
+	}
 
-		fmt.Printf("Creating deployment\n")
-		if err := r.Create(context.Background(), applier); err != nil {
-			fmt.Printf("failed to update %v\n", err)
+	if instance.Status.Applier != nil {
+		err := r.Client.Get(context.Background(),
+			types.NamespacedName{Name: instance.Status.Applier.Name,
+				Namespace: instance.Status.Applier.Namespace,
+			}, applier)
+		if errors.IsNotFound(err) {
+			// The Deployment was deleted out-of-band.  Clear the stale reference and fall
+			// through to adoption / creation below.
+			fmt.Printf("applier deployment deleted out-of-band, recreating\n")
+			instance.Status.Applier = nil
+		} else if err != nil {
+			fmt.Printf("failed to find applier %v\n", err)
 			return reconcile.Result{}, err
+		} else {
+			applier.Spec = spec
+			fmt.Printf("Updating applier\n")
+			if err := r.Update(context.Background(), applier); err != nil {
+				fmt.Printf("failed to update %v\n", err)
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{}, nil
 		}
+	}
 
-		// Update the instance with the object reference
-		instance.Spec.Components.Applier = &corev1.ObjectReference{
-			Namespace:       applier.Namespace,
-			Name:            applier.Name,
-			Kind:            "Deployment",
-			APIVersion:      "apps/v1",
-			UID:             applier.UID,
-			ResourceVersion: applier.ResourceVersion,
+	// Adopt an existing Deployment found by the label selector in case the reference write
+	// failed after a previous create
+	existing := &appsv1.DeploymentList{}
+	if err := r.List(context.Background(),
+		client.InNamespace(instance.Namespace).MatchingLabels(applier.Labels), existing); err != nil {
+		return reconcile.Result{}, err
+	}
+	for i := range existing.Items {
+		d := &existing.Items[i]
+		if !metav1.IsControlledBy(d, instance) {
+			continue
 		}
-		fmt.Printf("Updating applier\n")
-		if err := r.Update(context.Background(), instance); err != nil {
+		fmt.Printf("Adopting deployment %s\n", d.Name)
+		d.Spec = spec
+		if err := r.Update(context.Background(), d); err != nil {
 			fmt.Printf("failed to update %v\n", err)
 			return reconcile.Result{}, err
 		}
-		return reconcile.Result{}, nil
+		return r.setApplierRef(instance, d)
 	}
 
-	if err := r.Client.Get(context.Background(),
-		types.NamespacedName{Name: instance.Spec.Components.Applier.Name,
-			Namespace: instance.Spec.Components.Applier.Namespace,
-		}, applier); err != nil {
-		fmt.Printf("failed to find applier %v\n", err)
+	// Create the applier object
+	applier.Spec = spec
+	applier.GenerateName = fmt.Sprintf("%s-", instance.Name)
+	applier.Namespace = instance.Namespace
+	if err := controllerutil.SetControllerReference(instance, applier, r.scheme); err != nil {
+		fmt.Printf("failed to set reference %v\n", err)
 		return reconcile.Result{}, err
 	}
-	applier.Spec = spec
-	fmt.Printf("Updating applier\n")
-	if err := r.Update(context.Background(), applier); err != nil {
+
+	fmt.Printf("Creating deployment\n")
+	if err := r.Create(context.Background(), applier); err != nil {
 		fmt.Printf("failed to update %v\n", err)
 		return reconcile.Result{}, err
 	}
 
-	// TODO: Update the deployment args
+	return r.setApplierRef(instance, applier)
+}
 
+// setApplierRef records the created or adopted Deployment on the ContinuousApply status
+func (r *ReconcileContinuousApply) setApplierRef(instance *applyv1beta1.ContinuousApply,
+	applier *appsv1.Deployment) (reconcile.Result, error) {
+
+	instance.Status.Applier = &corev1.ObjectReference{
+		Namespace:       applier.Namespace,
+		Name:            applier.Name,
+		Kind:            "Deployment",
+		APIVersion:      "apps/v1",
+		UID:             applier.UID,
+		ResourceVersion: applier.ResourceVersion,
+	}
+	fmt.Printf("Updating applier\n")
+	if err := r.Update(context.Background(), instance); err != nil {
+		fmt.Printf("failed to update %v\n", err)
+		return reconcile.Result{}, err
+	}
 	return reconcile.Result{}, nil
 }
+
+// toolsInitScript downloads the pinned tool versions for the node's architecture into
+// /tools and verifies them against the published digests, so the applier's kubectl and
+// kustomize match the cluster regardless of what is baked into the image
+func toolsInitScript(tools applyv1beta1.ToolVersions) string {
+	s := "set -e\n" +
+		"ARCH=$(uname -m)\n" +
+		"case $ARCH in x86_64) ARCH=amd64 ;; aarch64) ARCH=arm64 ;; esac\n"
+	if tools.Kubectl != "" {
+		s += fmt.Sprintf("curl -fsSL -o /tools/kubectl "+
+			"https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/${ARCH}/kubectl\n",
+			tools.Kubectl)
+		s += digestChecks("/tools/kubectl", tools.KubectlSHA256)
+		s += "chmod +x /tools/kubectl\n"
+	}
+	if tools.Kustomize != "" {
+		s += fmt.Sprintf("curl -fsSL -o /tools/kustomize "+
+			"https://github.com/kubernetes-sigs/kustomize/releases/download/v%s/kustomize_%s_linux_${ARCH}\n",
+			tools.Kustomize, tools.Kustomize)
+		s += digestChecks("/tools/kustomize", tools.KustomizeSHA256)
+		s += "chmod +x /tools/kustomize\n"
+	}
+	return s
+}
+
+// digestChecks verifies a downloaded binary against the sha256 digest published for the
+// node's architecture.  Architectures without a digest are not verified.
+func digestChecks(path string, digests map[string]string) string {
+	s := ""
+	for _, arch := range sortedKeys(digests) {
+		s += fmt.Sprintf("[ \"$ARCH\" != %s ] || echo \"%s  %s\" | sha256sum -c -\n",
+			arch, digests[arch], path)
+	}
+	return s
+}
+
+// sortedKeys returns the map keys in a stable order so generated args don't churn between
+// reconciles
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeysInt64(m map[string]int64) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2018 The Kubernetes authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package continuousapply
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	applyv1beta1 "github.com/pwittrock/continuous-apply/pkg/apis/apply/v1beta1"
+)
+
+// WatchNamespace limits reconciling to ContinuousApply objects in one namespace, letting
+// platform teams run a controller per tenant.  Empty reconciles cluster-wide.
+var WatchNamespace string
+
+// MaxAppliersPerNamespace caps how many applier Deployments may run in any one namespace.
+// Zero is unlimited.  NamespaceDefaults.MaxAppliers overrides the cap per namespace.
+var MaxAppliersPerNamespace int
+
+// MaxAppliersPerRepo caps how many appliers may poll the same owner/repo across all
+// namespaces, protecting the GitHub API quota of the bot account.  Zero is unlimited.
+var MaxAppliersPerRepo int
+
+// NamespaceDefaults are per-namespace settings applied to tenant ContinuousApply objects,
+// so platform teams can delegate CR creation without tenants picking their own image or
+// pointing the applier at arbitrary repos
+type NamespaceDefaults struct {
+	// Image overrides the applier image for the namespace
+	Image string `json:"image,omitempty"`
+
+	// PollInterval is the default pause between rollout status checks, e.g. 5s
+	PollInterval string `json:"pollInterval,omitempty"`
+
+	// AllowedRepos restricts which owner/repo values objects in the namespace may
+	// reference.  Empty allows all repos.
+	AllowedRepos []string `json:"allowedRepos,omitempty"`
+
+	// MaxAppliers caps how many applier Deployments may run in the namespace, overriding
+	// MaxAppliersPerNamespace.  Zero keeps the cluster-wide cap.
+	MaxAppliers int `json:"maxAppliers,omitempty"`
+}
+
+// namespaceDefaults holds the per-namespace settings, keyed by namespace
+var namespaceDefaults = map[string]NamespaceDefaults{}
+
+// LoadNamespaceDefaults reads per-namespace defaults from a yaml file keyed by namespace
+func LoadNamespaceDefaults(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(b, &namespaceDefaults); err != nil {
+		return fmt.Errorf("could not parse namespace defaults %s: %v", path, err)
+	}
+	return nil
+}
+
+// repoAllowed returns true if the namespace's AllowedRepos permits the instance's repo.
+// An empty list allows all repos.
+func repoAllowed(defaults NamespaceDefaults, instance *applyv1beta1.ContinuousApply) bool {
+	if len(defaults.AllowedRepos) == 0 {
+		return true
+	}
+	repo := fmt.Sprintf("%s/%s", instance.Spec.Repo.Owner, instance.Spec.Repo.Repo)
+	for _, allowed := range defaults.AllowedRepos {
+		if allowed == repo {
+			return true
+		}
+	}
+	return false
+}
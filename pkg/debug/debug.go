@@ -0,0 +1,40 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package debug serves the runtime profiling and debug endpoints used to profile memory
+// growth in long-running pollers
+package debug
+
+import (
+	"log"
+	"net/http"
+
+	// Register /debug/vars and /debug/pprof on the default mux
+	_ "expvar"
+	_ "net/http/pprof"
+)
+
+// Serve exposes /debug/pprof and /debug/vars on the address in the background.  An empty
+// address disables the endpoints.
+func Serve(address string) {
+	if address == "" {
+		return
+	}
+	go func() {
+		log.Printf("serving debug endpoints on %s\n", address)
+		log.Printf("debug server exited %v", http.ListenAndServe(address, nil))
+	}()
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// AskPassEnv returns environment variables pointing git at an askpass helper that
+// supplies the access token, for tools that exec git outside the checkout - e.g.
+// kustomize fetching private remote bases.  Like the credential helper, the token never
+// appears in argv or the script itself.  The script is written once per commit and
+// reused across renders.
+func (m *GitManager) AskPassEnv() ([]string, error) {
+	m.tokenMu.Lock()
+	defer m.tokenMu.Unlock()
+
+	if m.askPassFile == "" {
+		password := fmt.Sprintf(`echo "${%s}"`, tokenVarName)
+		if m.TokenFile != "" {
+			password = "cat " + m.TokenFile
+		}
+		if m.TokenCommand != "" {
+			password = m.TokenCommand
+		}
+		script := fmt.Sprintf(`#!/bin/sh
+case "$1" in
+Username*) echo "x-access-token" ;;
+Password*) %s ;;
+esac
+`, password)
+
+		f := filepath.Join(os.TempDir(), fmt.Sprintf("askpass-%s-%s.sh", m.Repo, m.Commit))
+		if err := ioutil.WriteFile(f, []byte(script), 0700); err != nil {
+			return nil, fmt.Errorf("could not write askpass helper: %v", err)
+		}
+		m.askPassFile = f
+	}
+
+	env := []string{"GIT_ASKPASS=" + m.askPassFile, "GIT_TERMINAL_PROMPT=0"}
+	if m.Proxy != "" {
+		env = append(env, "HTTP_PROXY="+m.Proxy, "HTTPS_PROXY="+m.Proxy)
+	}
+	if m.CABundle != "" {
+		env = append(env, "GIT_SSL_CAINFO="+m.CABundle)
+	}
+	return env, nil
+}
@@ -18,18 +18,42 @@ package git
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/google/go-github/github"
+	"github.com/pwittrock/continuous-apply/pkg/audit"
 	"golang.org/x/oauth2"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 const (
 	tokenVarName = "GIT_ACCESS_TOKEN"
+
+	// tokenFileVarName points at a file containing the access token - e.g. a projected
+	// Secret volume - keeping the token out of the pod's environment
+	tokenFileVarName = "GIT_ACCESS_TOKEN_FILE"
+
+	// tokenCommandVarName holds a command printing a short-lived access token to stdout -
+	// e.g. a vault or gcloud secrets invocation - so long-lived tokens never live in the
+	// cluster at all
+	tokenCommandVarName = "GIT_ACCESS_TOKEN_COMMAND"
+
+	// tokenTTLVarName overrides how long a command-fetched token is cached before the
+	// command is re-run, e.g. 5m
+	tokenTTLVarName = "GIT_ACCESS_TOKEN_TTL"
 )
 
 type GitManager struct {
@@ -38,23 +62,380 @@ type GitManager struct {
 	Repo        string
 	Owner       string
 	Commit      string
+
+	// TokenFile is a file containing the access token - e.g. a projected Secret volume.
+	// The file is re-read on every request so Secret rotations take effect without a
+	// restart.  Empty uses the AccessToken.
+	TokenFile string
+
+	// TokenCommand is a command printing a short-lived access token to stdout - e.g. a
+	// vault or gcloud secrets invocation - re-run every TokenTTL so renewals are
+	// transparent.  Takes precedence over TokenFile and AccessToken.
+	TokenCommand string
+
+	// TokenTTL is how long a TokenCommand token is cached before the command is re-run.
+	// Zero uses 5 minutes.
+	TokenTTL time.Duration
+
+	// tokenMu guards the cached TokenCommand token and the seen-token history
+	tokenMu      sync.Mutex
+	cachedToken  string
+	tokenFetched time.Time
+
+	// seenTokens holds every token read from the token file so tokens rotated away
+	// mid-operation are still redacted from logs and errors
+	seenTokens []string
+
+	// askPassFile is the per-commit askpass helper script, written on first use
+	askPassFile string
+
+	// WorkDir is the directory to clone the repo into.  Empty uses the current directory.
+	WorkDir string
+
+	// CommitterName and CommitterEmail configure the git identity used for write operations
+	CommitterName  string
+	CommitterEmail string
+
+	// Proxy is an HTTP(S) proxy URL used for git and GitHub API traffic
+	Proxy string
+
+	// APIEndpoint is the base URL for GitHub API requests.  Point it at a caching proxy
+	// (e.g. ghproxy) to cut API usage when running many appliers against one org - the
+	// proxy partitions its cache by the access token it receives.
+	APIEndpoint string
+
+	// CABundle is a PEM file of additional CAs to trust for git and GitHub API traffic.
+	// Useful for GitHub Enterprise instances behind a corporate CA.
+	CABundle string
+
+	// SyncStrategy controls how aggressively the work tree is cleaned when syncing.  force
+	// discards modified tracked files, removes untracked and ignored files, and initializes
+	// submodules.  Empty keeps the default of only removing untracked files.
+	SyncStrategy string
+
+	// Submodules initializes and updates git submodules when syncing, for repos vendoring
+	// bases via submodules
+	Submodules bool
+
+	// LFS pulls git-lfs content when syncing, for repos storing large files via LFS
+	LFS bool
+
+	// SparsePaths limits the checkout to the listed paths plus any kustomize bases they
+	// reference, reducing clone time and disk usage in monorepos.  Empty checks out the
+	// full tree.
+	SparsePaths []string
+
+	// RequestInterval is the minimum time between GitHub API requests.  Configured so
+	// appliers sharing one bot account collectively stay under the API rate limit.
+	RequestInterval time.Duration
+
+	// RequestTimeout bounds each GitHub API request so a hung connection cannot stall the
+	// poll loop.  Zero uses a 30 second default.
+	RequestTimeout time.Duration
+
+	// MaxRetries retries failed GitHub API reads with backoff before giving up
+	MaxRetries int
+
+	// DryRun logs GitHub writes instead of performing them
+	DryRun bool
+
+	// Audit optionally records GitHub mutations
+	Audit *audit.Logger
+
+	// worktreeMu serializes worktree creation and removal so concurrent renders cannot race
+	worktreeMu sync.Mutex
+}
+
+// auditLog records a GitHub mutation in the audit log
+func (m *GitManager) auditLog(action string, issue int, err error) {
+	m.Audit.Log(audit.Entry{
+		Action:  action,
+		Object:  fmt.Sprintf("%s/%s#%d", m.Owner, m.Repo, issue),
+		Commit:  m.Commit,
+		Outcome: audit.Outcome(err),
+	})
 }
 
 func NewManager(owner, repo, commit string) (*GitManager, error) {
-	if os.Getenv(tokenVarName) == "" {
-		return nil, fmt.Errorf("must define %s environment variable", tokenVarName)
+	m := &GitManager{
+		AccessToken:  strings.TrimSpace(os.Getenv(tokenVarName)),
+		TokenFile:    strings.TrimSpace(os.Getenv(tokenFileVarName)),
+		TokenCommand: strings.TrimSpace(os.Getenv(tokenCommandVarName)),
+		Commit:       commit,
+		Repo:         repo,
+		Owner:        owner,
+	}
+	if m.AccessToken == "" && m.TokenFile == "" && m.TokenCommand == "" {
+		return nil, fmt.Errorf("must define %s, %s, or %s environment variable",
+			tokenVarName, tokenFileVarName, tokenCommandVarName)
+	}
+	if ttl := strings.TrimSpace(os.Getenv(tokenTTLVarName)); ttl != "" {
+		var err error
+		if m.TokenTTL, err = time.ParseDuration(ttl); err != nil {
+			return nil, fmt.Errorf("could not parse %s %s: %v", tokenTTLVarName, ttl, err)
+		}
+	}
+
+	m.Client = github.NewClient(oauth2.NewClient(context.Background(), m.tokenSource()))
+	return m, nil
+}
+
+// rememberToken records a token read from the token file so stale tokens from before a
+// rotation are still redacted from logs and errors
+func (m *GitManager) rememberToken(token string) {
+	m.tokenMu.Lock()
+	defer m.tokenMu.Unlock()
+	for _, t := range m.seenTokens {
+		if t == token {
+			return
+		}
+	}
+	m.seenTokens = append(m.seenTokens, token)
+}
+
+// tokenSource returns a token source fetching through the token command when one is
+// configured, reading from the token file when one is configured, and the static token
+// otherwise
+func (m *GitManager) tokenSource() oauth2.TokenSource {
+	if m.TokenCommand != "" {
+		return &commandTokenSource{m: m}
+	}
+	if m.TokenFile != "" {
+		return &fileTokenSource{m: m}
+	}
+	return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: m.AccessToken})
+}
+
+// defaultTokenTTL is how long a TokenCommand token is cached when no TTL is configured
+const defaultTokenTTL = 5 * time.Minute
+
+// commandToken returns the cached TokenCommand token, re-running the command once the TTL
+// elapses so short-lived credentials are renewed transparently
+func (m *GitManager) commandToken() (string, error) {
+	m.tokenMu.Lock()
+	defer m.tokenMu.Unlock()
+
+	ttl := m.TokenTTL
+	if ttl == 0 {
+		ttl = defaultTokenTTL
+	}
+	if m.cachedToken != "" && time.Since(m.tokenFetched) < ttl {
+		return m.cachedToken, nil
 	}
 
-	token := strings.TrimSpace(os.Getenv(tokenVarName))
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	return &GitManager{
-		Client:      github.NewClient(oauth2.NewClient(ctx, ts)),
-		AccessToken: token,
-		Commit:      commit,
-		Repo:        repo,
-		Owner:       owner,
-	}, nil
+	out, err := exec.Command("sh", "-c", m.TokenCommand).Output()
+	if err != nil {
+		return "", fmt.Errorf("token command failed: %v", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("token command returned an empty token")
+	}
+	m.cachedToken = token
+	m.tokenFetched = time.Now()
+	return token, nil
+}
+
+// commandTokenSource fetches tokens through commandToken so the oauth2 transport picks up
+// renewals without a rebuild
+type commandTokenSource struct {
+	m *GitManager
+}
+
+func (t *commandTokenSource) Token() (*oauth2.Token, error) {
+	token, err := t.m.commandToken()
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: token}, nil
+}
+
+// fileTokenSource re-reads the token file on every request so rotated Secret mounts take
+// effect without a restart
+type fileTokenSource struct {
+	m *GitManager
+}
+
+func (t *fileTokenSource) Token() (*oauth2.Token, error) {
+	b, err := ioutil.ReadFile(t.m.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(string(b))
+	t.m.rememberToken(token)
+	return &oauth2.Token{AccessToken: token}, nil
+}
+
+// credentialHelper supplies the access token to git without it ever appearing in argv or
+// the remote URL, reading the token file when one is configured so rotations take effect
+func (m *GitManager) credentialHelper() string {
+	if m.TokenCommand != "" {
+		return `!f() { echo "username=x-access-token"; echo "password=$(` + m.TokenCommand + `)"; }; f`
+	}
+	if m.TokenFile != "" {
+		return `!f() { echo "username=x-access-token"; echo "password=$(cat ` + m.TokenFile + `)"; }; f`
+	}
+	return `!f() { echo "username=x-access-token"; echo "password=${` + tokenVarName + `}"; }; f`
+}
+
+// git returns a git command running with the configured proxy, CA bundle, and identity
+func (m *GitManager) git(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	env := os.Environ()
+	if m.Proxy != "" {
+		env = append(env, "HTTP_PROXY="+m.Proxy, "HTTPS_PROXY="+m.Proxy)
+	}
+	if m.CABundle != "" {
+		env = append(env, "GIT_SSL_CAINFO="+m.CABundle)
+	}
+	if m.CommitterName != "" {
+		env = append(env, "GIT_COMMITTER_NAME="+m.CommitterName, "GIT_AUTHOR_NAME="+m.CommitterName)
+	}
+	if m.CommitterEmail != "" {
+		env = append(env, "GIT_COMMITTER_EMAIL="+m.CommitterEmail, "GIT_AUTHOR_EMAIL="+m.CommitterEmail)
+	}
+	cmd.Env = env
+	return cmd
+}
+
+const defaultRequestTimeout = 30 * time.Second
+
+// breakerThreshold is the number of consecutive failed requests before the circuit opens
+const breakerThreshold = 5
+
+// breakerCooldown is how long requests are refused after the circuit opens
+const breakerCooldown = 2 * time.Minute
+
+// retryTransport retries failed reads with backoff, and refuses requests for a cooldown
+// period after repeated consecutive failures so a broken GitHub API is not hammered
+type retryTransport struct {
+	delegate http.RoundTripper
+	retries  int
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	if t.failures >= breakerThreshold && time.Since(t.openedAt) < breakerCooldown {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("github api circuit open for %s after %d consecutive failures",
+			breakerCooldown, breakerThreshold)
+	}
+	t.mu.Unlock()
+
+	// Only reads can be retried safely - the request body has been consumed for writes
+	retries := t.retries
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		retries = 0
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		resp, err = t.delegate.RoundTrip(req)
+		if err == nil && resp.StatusCode < 500 {
+			t.mu.Lock()
+			t.failures = 0
+			t.mu.Unlock()
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("github api returned %s", resp.Status)
+		}
+	}
+
+	t.mu.Lock()
+	t.failures++
+	if t.failures >= breakerThreshold {
+		// Re-open on every failure past the threshold so a failed probe after the
+		// cooldown starts a fresh cooldown
+		t.openedAt = time.Now()
+	}
+	t.mu.Unlock()
+	return nil, err
+}
+
+// rateLimitTransport spaces requests out by a minimum interval before delegating them
+type rateLimitTransport struct {
+	delegate http.RoundTripper
+	interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	if sleep := t.interval - time.Since(t.last); sleep > 0 {
+		time.Sleep(sleep)
+	}
+	t.last = time.Now()
+	t.mu.Unlock()
+	return t.delegate.RoundTrip(req)
+}
+
+// ConfigureTransport rebuilds the GitHub API client to route traffic through the configured
+// proxy, trust the configured CA bundle, and honor the configured timeout, retry, and
+// request interval settings.  Must be called before making API calls.
+func (m *GitManager) ConfigureTransport() error {
+	transport := &http.Transport{}
+	if m.Proxy != "" {
+		u, err := url.Parse(m.Proxy)
+		if err != nil {
+			return fmt.Errorf("could not parse proxy url %s: %v", m.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	if m.CABundle != "" {
+		b, err := ioutil.ReadFile(m.CABundle)
+		if err != nil {
+			return err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return fmt.Errorf("no certificates found in %s", m.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	var rt http.RoundTripper = transport
+	if m.RequestInterval > 0 {
+		rt = &rateLimitTransport{delegate: rt, interval: m.RequestInterval}
+	}
+	if m.MaxRetries > 0 {
+		rt = &retryTransport{delegate: rt, retries: m.MaxRetries}
+	}
+
+	timeout := m.RequestTimeout
+	if timeout == 0 {
+		timeout = defaultRequestTimeout
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient,
+		&http.Client{Transport: rt, Timeout: timeout})
+	m.Client = github.NewClient(oauth2.NewClient(ctx, m.tokenSource()))
+
+	if m.APIEndpoint != "" {
+		u, err := url.Parse(m.APIEndpoint)
+		if err != nil {
+			return fmt.Errorf("could not parse github endpoint %s: %v", m.APIEndpoint, err)
+		}
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		m.Client.BaseURL = u
+	}
+	return nil
 }
 
 func (m *GitManager) SyncRepo() error {
@@ -71,21 +452,59 @@ func (m *GitManager) SyncRepo() error {
 }
 
 func (m *GitManager) gitUrl() string {
-	return fmt.Sprintf("https://x-accessToken-tokenVarName:%s@github.com/%s/%s", m.AccessToken, m.Owner, m.Repo)
+	return fmt.Sprintf("https://github.com/%s/%s", m.Owner, m.Repo)
+}
+
+// redact removes the access token from s so it can be safely logged or returned in errors
+func (m *GitManager) redact(s string) string {
+	tokens := []string{m.AccessToken}
+	m.tokenMu.Lock()
+	if m.TokenCommand != "" {
+		tokens = append(tokens, m.cachedToken)
+	}
+	tokens = append(tokens, m.seenTokens...)
+	m.tokenMu.Unlock()
+	if m.TokenFile != "" {
+		if b, err := ioutil.ReadFile(m.TokenFile); err == nil {
+			tokens = append(tokens, strings.TrimSpace(string(b)))
+		}
+	}
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		s = strings.Replace(s, token, "[REDACTED]", -1)
+	}
+	return s
 }
 
 func (m *GitManager) Clone() error {
-	err := exec.Command("git", "remote").Run()
+	if m.WorkDir != "" {
+		if err := os.MkdirAll(m.WorkDir, 0700); err != nil {
+			return err
+		}
+		if err := os.Chdir(m.WorkDir); err != nil {
+			return err
+		}
+	}
+
+	err := m.git("remote").Run()
 	if err == nil {
 		return nil
 	}
 
 	log.Printf("clone started\n")
 
-	cmd := exec.Command("git", "clone", m.gitUrl())
+	// The credential helper is written into the cloned repo config so later fetches and
+	// pushes are authenticated without the token appearing in the remote URL
+	args := []string{"clone", "--config", "credential.helper=" + m.credentialHelper()}
+	if len(m.SparsePaths) > 0 {
+		args = append(args, "--filter=blob:none", "--sparse")
+	}
+	cmd := m.git(append(args, m.gitUrl())...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	log.Printf(strings.Join(cmd.Args, " "))
+	log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
 	err = cmd.Run()
 	if err != nil {
 		return err
@@ -93,69 +512,242 @@ func (m *GitManager) Clone() error {
 
 	log.Printf("clone finish\n")
 
-	return os.Chdir(m.Repo)
+	if err := os.Chdir(m.Repo); err != nil {
+		return err
+	}
+	return m.setSparseCheckout(m.SparsePaths)
 }
 
-func (m *GitManager) fetch() error {
-	log.Printf("syncing to %s\n", m.Commit)
-	err := exec.Command("git", "branch", "--contains", m.Commit).Run()
-	if err == nil {
+// setSparseCheckout limits the work tree to the paths.  No-op for full checkouts.
+func (m *GitManager) setSparseCheckout(paths []string) error {
+	if len(paths) == 0 {
 		return nil
 	}
+	cmd := m.git(append([]string{"sparse-checkout", "set"}, paths...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
+	return cmd.Run()
+}
+
+// expandSparseCheckout grows the sparse checkout to include the kustomize bases the sparse
+// paths reference from outside themselves, so sparse checkouts of monorepos still render
+// completely.  Newly materialized bases are re-scanned until no new references appear.
+func (m *GitManager) expandSparseCheckout() error {
+	known := sets.NewString(m.SparsePaths...)
+	for i := 0; i < 10; i++ {
+		added := false
+		for _, p := range known.List() {
+			for _, base := range kustomizeBases(p) {
+				if !known.Has(base) {
+					known.Insert(base)
+					added = true
+				}
+			}
+		}
+		if !added {
+			return nil
+		}
+		if err := m.setSparseCheckout(known.List()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kustomizeBases returns the directories the path's kustomization references outside the
+// path itself
+func kustomizeBases(path string) []string {
+	var bases []string
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml"} {
+		b, err := ioutil.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			continue
+		}
+		k := struct {
+			Bases     []string `json:"bases"`
+			Resources []string `json:"resources"`
+		}{}
+		if err := yaml.Unmarshal(b, &k); err != nil {
+			continue
+		}
+		for _, r := range append(k.Bases, k.Resources...) {
+			if !strings.HasPrefix(r, "..") {
+				continue
+			}
+			bases = append(bases, filepath.Clean(filepath.Join(path, r)))
+		}
+	}
+	return bases
+}
+
+// Worktree checks the commit out into a separate work tree so it can be rendered
+// concurrently with the main checkout, returning the work tree path.  Work trees are
+// reused between calls for the same commit.
+func (m *GitManager) Worktree(commit string) (string, error) {
+	m.worktreeMu.Lock()
+	defer m.worktreeMu.Unlock()
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("continuous-apply-worktree-%s", commit))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
 
-	cmd := exec.Command("git", "fetch", "origin")
+	cmd := m.git("worktree", "add", "--detach", path, commit)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	log.Printf(strings.Join(cmd.Args, " "))
+	log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
 	if err := cmd.Run(); err != nil {
-		return err
+		return "", err
+	}
+	return path, nil
+}
+
+// RemoveWorktree removes the commit's work tree once it is no longer needed
+func (m *GitManager) RemoveWorktree(commit string) error {
+	m.worktreeMu.Lock()
+	defer m.worktreeMu.Unlock()
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("continuous-apply-worktree-%s", commit))
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	cmd := m.git("worktree", "remove", "--force", path)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
+	return cmd.Run()
+}
+
+func (m *GitManager) fetch() error {
+	log.Printf("syncing to %s\n", m.Commit)
+	err := m.git("branch", "--contains", m.Commit).Run()
+	if err == nil {
+		return m.cleanWorkTree()
 	}
 
-	cmd = exec.Command("git", "branch", "--contains", m.Commit)
+	cmd := m.git("fetch", "origin")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	log.Printf(strings.Join(cmd.Args, " "))
+	log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
 	if err := cmd.Run(); err != nil {
 		return err
 	}
 
-	// Reset to the merge commit
-	cmd = exec.Command("git", "clean", "-f")
+	cmd = m.git("branch", "--contains", m.Commit)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	log.Printf(strings.Join(cmd.Args, " "))
+	log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
 	if err := cmd.Run(); err != nil {
 		return err
 	}
 
+	return m.cleanWorkTree()
+}
+
+// cleanWorkTree discards local changes per the configured sync strategy so checkout cannot
+// fail on a dirty work tree.  The force strategy also discards modified tracked files and
+// ignored files.
+func (m *GitManager) cleanWorkTree() error {
+	commands := [][]string{{"clean", "-f"}}
+	if m.SyncStrategy == "force" {
+		commands = [][]string{{"reset", "--hard"}, {"clean", "-ffdx"}}
+	}
+	for _, args := range commands {
+		cmd := m.git(args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (m *GitManager) checkout() error {
-	cmd := exec.Command("git", "checkout", m.Commit)
+	cmd := m.git("checkout", m.Commit)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	log.Printf(strings.Join(cmd.Args, " "))
+	log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
 	if err := cmd.Run(); err != nil {
 		return err
 	}
 
+	// Pull in kustomize bases referenced from outside the sparse paths
+	if len(m.SparsePaths) > 0 {
+		if err := m.expandSparseCheckout(); err != nil {
+			return err
+		}
+	}
+
+	// Repos with submodules only render completely once the submodules are checked out
+	if m.SyncStrategy == "force" || m.Submodules {
+		cmd = m.git("submodule", "update", "--init", "--recursive")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+
+	// Repos storing large files via LFS only have pointer files until the content is pulled
+	if m.LFS {
+		cmd = m.git("lfs", "pull")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		log.Printf("%s", m.redact(strings.Join(cmd.Args, " ")))
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// Comment posts a free-form comment on the issue
+func (m *GitManager) Comment(issue int, body string) error {
+	if m.DryRun {
+		log.Printf("dry-run: comment on issue %d: %s\n", issue, body)
+		return nil
+	}
+	_, _, err := m.Issues.CreateComment(context.Background(), m.Owner, m.Repo, issue,
+		&github.IssueComment{Body: &body})
+	m.auditLog("comment", issue, err)
+	return err
+}
+
 func (m *GitManager) AddLabels(issue int, labels ...string) error {
+	if m.DryRun {
+		log.Printf("dry-run: add labels %v to issue %d\n", labels, issue)
+		return nil
+	}
 	_, _, err := m.Issues.AddLabelsToIssue(context.TODO(), m.Owner, m.Repo, issue, labels)
+	m.auditLog(fmt.Sprintf("add-labels %v", labels), issue, err)
 	return err
 }
 
 func (m *GitManager) AddAssignees(issue int, assignees ...string) error {
+	if m.DryRun {
+		log.Printf("dry-run: add assignees %v to issue %d\n", assignees, issue)
+		return nil
+	}
 	_, _, err := m.Issues.AddAssignees(context.TODO(), m.Owner, m.Repo, issue, assignees)
+	m.auditLog(fmt.Sprintf("add-assignees %v", assignees), issue, err)
 	return err
 }
 
 func (m *GitManager) RemoveLabels(issue int, labels ...string) error {
+	if m.DryRun {
+		log.Printf("dry-run: remove labels %v from issue %d\n", labels, issue)
+		return nil
+	}
 	for _, l := range labels {
-		if _, err := m.Issues.RemoveLabelForIssue(context.TODO(), m.Owner, m.Repo, issue, l); err != nil {
+		_, err := m.Issues.RemoveLabelForIssue(context.TODO(), m.Owner, m.Repo, issue, l)
+		m.auditLog(fmt.Sprintf("remove-label %s", l), issue, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -163,7 +755,12 @@ func (m *GitManager) RemoveLabels(issue int, labels ...string) error {
 }
 
 func (m *GitManager) RemoveAssignees(issue int, assignees ...string) error {
+	if m.DryRun {
+		log.Printf("dry-run: remove assignees %v from issue %d\n", assignees, issue)
+		return nil
+	}
 	_, _, err := m.Issues.RemoveAssignees(context.TODO(), m.Owner, m.Repo, issue, assignees)
+	m.auditLog(fmt.Sprintf("remove-assignees %v", assignees), issue, err)
 	return err
 }
 
@@ -171,7 +768,8 @@ func (m *GitManager) commentPrefix(name string) string {
 	return fmt.Sprintf("[rollout]: %s", name)
 }
 
-func (m *GitManager) GetComment(name string, user string, issue int) (*github.IssueComment, error) {
+// FindComment returns the managed status comment on the issue, or nil if there is none
+func (m *GitManager) FindComment(name string, user string, issue int) (*github.IssueComment, error) {
 	comments, _, err := m.Issues.ListComments(context.TODO(), m.Owner, m.Repo, issue, nil)
 	if err != nil {
 		return nil, err
@@ -187,10 +785,20 @@ func (m *GitManager) GetComment(name string, user string, issue int) (*github.Is
 		fmt.Printf("comment not match %v [%s] [%s]\n", madeByUs, c.GetUser().GetLogin(), user)
 		fmt.Printf("comment not match %v [%s] [%s]\n", hasPrefix, c.GetBody(), expected)
 	}
+	return nil, nil
+}
 
+// CreateComment creates a fresh managed status comment on the issue
+func (m *GitManager) CreateComment(name string, issue int) (*github.IssueComment, error) {
 	body := m.commentPrefix(name)
 	comment := &github.IssueComment{Body: &body}
-	comment, _, err = m.Issues.CreateComment(context.TODO(), m.Owner, m.Repo, issue, comment)
+	if m.DryRun {
+		log.Printf("dry-run: create comment on issue %d\n", issue)
+		id := int64(0)
+		comment.ID = &id
+		return comment, nil
+	}
+	comment, _, err := m.Issues.CreateComment(context.TODO(), m.Owner, m.Repo, issue, comment)
 	if err != nil {
 		return nil, err
 	}
@@ -198,6 +806,42 @@ func (m *GitManager) GetComment(name string, user string, issue int) (*github.Is
 	return comment, nil
 }
 
+func (m *GitManager) GetComment(name string, user string, issue int) (*github.IssueComment, error) {
+	comment, err := m.FindComment(name, user, issue)
+	if err != nil || comment != nil {
+		return comment, err
+	}
+	return m.CreateComment(name, issue)
+}
+
+// ReactToComment adds a reaction to the comment, e.g. rocket when a rollout starts
+func (m *GitManager) ReactToComment(comment *github.IssueComment, content string) error {
+	if m.DryRun {
+		log.Printf("dry-run: react %s to comment %d\n", content, comment.GetID())
+		return nil
+	}
+	_, _, err := m.Reactions.CreateIssueCommentReaction(context.TODO(), m.Owner, m.Repo, comment.GetID(), content)
+	return err
+}
+
+// SupersededPrefix retags minimized status comments so they are no longer found as the
+// managed comment and can be garbage collected later
+const SupersededPrefix = "[rollout-superseded]:"
+
+// MinimizeComment collapses a superseded status comment into a details block and retags it
+// so it is no longer found as the managed comment
+func (m *GitManager) MinimizeComment(comment *github.IssueComment, name string) error {
+	body := fmt.Sprintf("%s %s\n\n<details><summary>superseded rollout status</summary>\n%s\n</details>",
+		SupersededPrefix, name, strings.TrimPrefix(comment.GetBody(), m.commentPrefix(name)))
+	if m.DryRun {
+		log.Printf("dry-run: minimize comment %d\n", comment.GetID())
+		return nil
+	}
+	comment.Body = &body
+	_, _, err := m.Issues.EditComment(context.TODO(), m.Owner, m.Repo, int(*comment.ID), comment)
+	return err
+}
+
 func (m *GitManager) UpdateComment(comment *github.IssueComment, name string, user string, issue int) (
 	*github.IssueComment, error) {
 
@@ -206,12 +850,85 @@ func (m *GitManager) UpdateComment(comment *github.IssueComment, name string, us
 		body := fmt.Sprintf("%s\n\n%s", expected, *comment.Body)
 		comment.Body = &body
 	}
+	if m.DryRun {
+		log.Printf("dry-run: update comment on issue %d:\n%s\n", issue, *comment.Body)
+		return comment, nil
+	}
 	comment, _, err := m.Issues.EditComment(context.TODO(), m.Owner, m.Repo, int(*comment.ID), comment)
 	return comment, err
 }
 
+// CreateGist uploads content as a secret gist and returns its URL
+func (m *GitManager) CreateGist(name string, content string) (string, error) {
+	if m.DryRun {
+		log.Printf("dry-run: create gist %s:\n%s\n", name, content)
+		return "", nil
+	}
+	public := false
+	g := &github.Gist{
+		Description: &name,
+		Public:      &public,
+		Files: map[github.GistFilename]github.GistFile{
+			github.GistFilename(name + ".json"): {Content: &content},
+		},
+	}
+	g, _, err := m.Gists.Create(context.TODO(), g)
+	if err != nil {
+		return "", err
+	}
+	return g.GetHTMLURL(), nil
+}
+
+// SetMilestone sets the issue or PR milestone by title
+func (m *GitManager) SetMilestone(issue int, title string) error {
+	if m.DryRun {
+		log.Printf("dry-run: set issue %d milestone to %s\n", issue, title)
+		return nil
+	}
+	milestones, _, err := m.Issues.ListMilestones(context.TODO(), m.Owner, m.Repo, nil)
+	if err != nil {
+		return err
+	}
+	for _, ms := range milestones {
+		if ms.GetTitle() == title {
+			num := ms.GetNumber()
+			_, _, err := m.Issues.Edit(context.TODO(), m.Owner, m.Repo, issue, &github.IssueRequest{Milestone: &num})
+			m.auditLog(fmt.Sprintf("set-milestone %s", title), issue, err)
+			return err
+		}
+	}
+	return fmt.Errorf("no milestone named %s in %s/%s", title, m.Owner, m.Repo)
+}
+
 func (m *GitManager) UpdateIssueState(issue int, state string) error {
+	if m.DryRun {
+		log.Printf("dry-run: set issue %d state to %s\n", issue, state)
+		return nil
+	}
 	i := &github.IssueRequest{State: &state}
 	_, _, err := m.Issues.Edit(context.TODO(), m.Owner, m.Repo, issue, i)
+	m.auditLog(fmt.Sprintf("set-state %s", state), issue, err)
+	return err
+}
+
+// LockIssue locks the issue's conversation
+func (m *GitManager) LockIssue(issue int) error {
+	if m.DryRun {
+		log.Printf("dry-run: lock issue %d\n", issue)
+		return nil
+	}
+	_, err := m.Issues.Lock(context.TODO(), m.Owner, m.Repo, issue)
+	m.auditLog("lock", issue, err)
+	return err
+}
+
+// DeleteComment deletes the issue comment
+func (m *GitManager) DeleteComment(comment *github.IssueComment) error {
+	if m.DryRun {
+		log.Printf("dry-run: delete comment %d\n", comment.GetID())
+		return nil
+	}
+	_, err := m.Issues.DeleteComment(context.TODO(), m.Owner, m.Repo, int(comment.GetID()))
+	m.auditLog(fmt.Sprintf("delete-comment %d", comment.GetID()), 0, err)
 	return err
 }
@@ -0,0 +1,109 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PushStatus commits content to file on the status branch and pushes it, creating the
+// branch if it does not exist yet.  The status worktree lives alongside the checkout and
+// is reused across pushes.  If another applier pushed first, the worktree is synced to the
+// remote head and the push retried once.
+func (m *GitManager) PushStatus(branch, file string, content []byte) error {
+	dir, err := m.statusWorktree(branch)
+	if err != nil {
+		return err
+	}
+
+	if err := m.commitStatus(dir, branch, file, content); err == nil {
+		return nil
+	}
+
+	for _, args := range [][]string{{"fetch", "origin", branch}, {"reset", "--hard", "origin/" + branch}} {
+		cmd := m.git(args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("could not sync status branch: %v %s", err, m.redact(string(out)))
+		}
+	}
+	return m.commitStatus(dir, branch, file, content)
+}
+
+// commitStatus writes the file into the status worktree, commits it, and pushes the branch
+func (m *GitManager) commitStatus(dir, branch, file string, content []byte) error {
+	if err := ioutil.WriteFile(filepath.Join(dir, file), content, 0644); err != nil {
+		return err
+	}
+
+	add := m.git("add", file)
+	add.Dir = dir
+	if out, err := add.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not stage status file: %v %s", err, m.redact(string(out)))
+	}
+
+	commit := m.git("commit", "-m", fmt.Sprintf("status: %s at %s", strings.TrimSuffix(file, filepath.Ext(file)), m.Commit))
+	commit.Dir = dir
+	if out, err := commit.CombinedOutput(); err != nil {
+		// The status did not change since the last push
+		if strings.Contains(string(out), "nothing to commit") {
+			return nil
+		}
+		return fmt.Errorf("could not commit status file: %v %s", err, m.redact(string(out)))
+	}
+
+	push := m.git("push", "origin", "HEAD:"+branch)
+	push.Dir = dir
+	if out, err := push.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not push status branch: %v %s", err, m.redact(string(out)))
+	}
+	return nil
+}
+
+// statusWorktree returns the worktree directory holding the status branch checkout,
+// creating it - and the branch, if the remote does not have one yet - on first use
+func (m *GitManager) statusWorktree(branch string) (string, error) {
+	dir := filepath.Join("..", m.Repo+"-"+branch)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	// The fetch fails when the branch does not exist yet - the checkout below starts it
+	_ = m.git("fetch", "origin", branch).Run()
+
+	if out, err := m.git("worktree", "add", "--detach", dir).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("could not add status worktree: %v %s", err, m.redact(string(out)))
+	}
+
+	co := m.git("checkout", "-B", branch, "origin/"+branch)
+	co.Dir = dir
+	if co.Run() != nil {
+		// No remote branch - start an orphan branch with an empty tree
+		for _, args := range [][]string{{"checkout", "--orphan", branch}, {"rm", "-rf", "--ignore-unmatch", "."}} {
+			cmd := m.git(args...)
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return "", fmt.Errorf("could not create status branch: %v %s", err, m.redact(string(out)))
+			}
+		}
+	}
+	return dir, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package issues
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pwittrock/continuous-apply/pkg/git"
+)
+
+// cleanupInterval is how often the retention policies are applied
+const cleanupInterval = time.Hour
+
+// Cleanup applies the retention policies, closing and locking old managed issues and
+// deleting superseded bot comments so the repo doesn't accumulate hundreds of them
+func (m *Manager) Cleanup() error {
+	if err := m.cleanupIssues(); err != nil {
+		return err
+	}
+	return m.cleanupComments()
+}
+
+// cleanupIssues closes and locks managed issues that have not been updated for the
+// configured retention duration
+func (m *Manager) cleanupIssues() error {
+	if m.Retention.CloseIssuesAfter == "" {
+		return nil
+	}
+	age, err := time.ParseDuration(m.Retention.CloseIssuesAfter)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-age)
+
+	issues, _, err := m.gitHubClient.Issues.ListByRepo(context.Background(), m.Repo.Owner, m.Repo.Repo,
+		&github.IssueListByRepoOptions{
+			Labels:    []string{m.Label},
+			State:     "all",
+			Sort:      "updated",
+			Direction: "asc",
+		})
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		if issue.IsPullRequest() || m.managedByOther(issue) {
+			continue
+		}
+		// Never collect the issue tracking the current rollout
+		if m.Issue != nil && issue.GetNumber() == m.Issue.GetNumber() {
+			continue
+		}
+		if issue.GetUpdatedAt().After(cutoff) {
+			continue
+		}
+		if issue.GetState() != "closed" {
+			log.Printf("closing stale issue %d\n", issue.GetNumber())
+			if err := m.gitClient.UpdateIssueState(issue.GetNumber(), "closed"); err != nil {
+				log.Printf("could not close issue %d %v", issue.GetNumber(), err)
+				continue
+			}
+		}
+		if !issue.GetLocked() {
+			log.Printf("locking stale issue %d\n", issue.GetNumber())
+			if err := m.gitClient.LockIssue(issue.GetNumber()); err != nil {
+				log.Printf("could not lock issue %d %v", issue.GetNumber(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// cleanupComments deletes superseded bot status comments that have not been updated for the
+// configured retention duration
+func (m *Manager) cleanupComments() error {
+	if m.Retention.DeleteSupersededCommentsAfter == "" {
+		return nil
+	}
+	age, err := time.ParseDuration(m.Retention.DeleteSupersededCommentsAfter)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-age)
+
+	// Issue number 0 lists comments across all issues in the repo
+	comments, _, err := m.gitHubClient.Issues.ListComments(context.Background(), m.Repo.Owner, m.Repo.Repo, 0,
+		&github.IssueListCommentsOptions{Sort: "updated", Direction: "asc"})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range comments {
+		if c.User.GetLogin() != m.User {
+			continue
+		}
+		if !strings.HasPrefix(c.GetBody(), git.SupersededPrefix) {
+			continue
+		}
+		if c.GetUpdatedAt().After(cutoff) {
+			continue
+		}
+		log.Printf("deleting superseded comment %d\n", c.GetID())
+		if err := m.gitClient.DeleteComment(c); err != nil {
+			log.Printf("could not delete comment %d %v", c.GetID(), err)
+		}
+	}
+	return nil
+}
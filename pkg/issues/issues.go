@@ -31,7 +31,6 @@ import (
 	"github.com/pwittrock/continuous-apply/pkg/apis/apply/v1beta1"
 	"github.com/pwittrock/continuous-apply/pkg/git"
 	"github.com/pwittrock/continuous-apply/pkg/poller"
-	"golang.org/x/oauth2"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -45,20 +44,21 @@ type Manager struct {
 	Issue        *github.Issue
 	PullRequest  *github.PullRequest
 	Commit       string
+
+	// ChangedPaths are the file paths changed by the PullRequest, for use in issue templates
+	ChangedPaths []string
 }
 
 func (m *Manager) Run() error {
-	token := strings.TrimSpace(m.AccessToken)
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	m.gitHubClient = github.NewClient(oauth2.NewClient(ctx, ts))
-
 	m.gitClient = &git.GitManager{
 		Owner:       m.Repo.Owner,
 		Repo:        m.Repo.Repo,
-		Client:      m.gitHubClient,
-		AccessToken: m.AccessToken,
+		AccessToken: strings.TrimSpace(m.AccessToken),
 	}
+	if err := m.gitClient.ConfigureTransport(); err != nil {
+		return err
+	}
+	m.gitHubClient = m.gitClient.Client
 	m.poller = &poller.Poller{
 		Repo:           m.Repo.Repo,
 		Owner:          m.Repo.Owner,
@@ -73,6 +73,7 @@ func (m *Manager) Run() error {
 		return err
 	}
 
+	var lastCleanup time.Time
 	for {
 		if err := m.SyncToPRAndIssue(); err != nil {
 			return err
@@ -80,6 +81,19 @@ func (m *Manager) Run() error {
 		if err := m.UpdateIssueStatus(); err != nil {
 			return err
 		}
+		// Mirror the appliers' statuses into the aggregate release issue
+		if m.ReleaseView {
+			if err := m.UpdateReleaseIssue(); err != nil {
+				log.Printf("release issue update failed %v", err)
+			}
+		}
+		// Periodically garbage collect old issues and superseded comments
+		if time.Since(lastCleanup) > cleanupInterval {
+			if err := m.Cleanup(); err != nil {
+				log.Printf("cleanup failed %v", err)
+			}
+			lastCleanup = time.Now()
+		}
 		time.Sleep(30 * time.Second)
 	}
 }
@@ -98,7 +112,7 @@ func (m *Manager) UpdateIssueStatus() error {
 	for _, l := range m.Issue.Labels {
 		s.Insert(l.GetName())
 	}
-	state := ""
+	allDone := len(m.StatusReporters) > 0
 	for _, t := range m.StatusReporters {
 		switch {
 		case s.HasAll(t.CompleteLabels...):
@@ -106,27 +120,58 @@ func (m *Manager) UpdateIssueStatus() error {
 			t.Status = "Complete"
 			t.Done = true
 			t.StatusIcon = doneIcon
-			state = "closed"
 		case s.HasAll(t.InProgressLabels...):
 			log.Printf("%s In Progress\n", t.Name)
 			t.Status = "In Progress"
 			t.Done = false
 			t.StatusIcon = inProgressIcon
-			state = "open"
 		default:
 			log.Printf("%s Pending\n", t.Name)
 			t.Status = "Pending"
 			t.Done = false
 			t.StatusIcon = ""
-			state = "open"
 		}
+		if !t.Done {
+			allDone = false
+		}
+	}
+
+	// Close only once every reporter is complete and the CloseIssue criteria are satisfied,
+	// e.g. a human has applied a required verification label
+	state := "open"
+	if allDone && m.matchesCloseCriteria(s) {
+		state = "closed"
+	}
+
+	if state == "closed" && m.Issue.GetState() != "closed" {
+		m.closeActions()
+		if m.CloseActions.SetState != "" {
+			state = m.CloseActions.SetState
+		}
+	}
+
+	b, err := m.issueBody()
+	if err != nil {
+		return err
 	}
+	b = preserveHumanSection(m.Issue.GetBody(), b)
 
-	buff := &bytes.Buffer{}
-	if err := bodyTemplate.Execute(buff, m); err != nil {
+	// Skip the write when nothing changed to avoid clobbering concurrent edits
+	if b == m.Issue.GetBody() && state == m.Issue.GetState() {
+		return nil
+	}
+
+	// Take the edit lease before writing so managers sharing the repo don't race
+	locked, err := m.acquireLock(m.Issue)
+	if err != nil {
 		return err
 	}
-	b := buff.String()
+	if !locked {
+		log.Printf("issue %d is locked by another manager\n", m.Issue.GetNumber())
+		return nil
+	}
+	defer m.releaseLock(m.Issue)
+
 	m.Issue, _, err = m.gitHubClient.Issues.Edit(context.Background(), m.Repo.Owner, m.Repo.Repo, m.Issue.GetNumber(),
 		&github.IssueRequest{
 			State: &state,
@@ -135,8 +180,112 @@ func (m *Manager) UpdateIssueStatus() error {
 	return err
 }
 
+// humanSectionMarker separates the generated issue body from notes added by humans
+const humanSectionMarker = "<!-- notes below this line are preserved -->"
+
+// preserveHumanSection appends any content a human added below the marker in the current
+// body to the newly rendered body so status updates don't clobber it
+func preserveHumanSection(current, rendered string) string {
+	i := strings.Index(current, humanSectionMarker)
+	if i < 0 {
+		return rendered + "\n" + humanSectionMarker + "\n"
+	}
+	return rendered + "\n" + current[i:]
+}
+
+// matchesCloseCriteria returns true if the issue satisfies the CloseIssue filters
+func (m *Manager) matchesCloseCriteria(labels sets.String) bool {
+	if !labels.HasAll(m.CloseIssue.Labels...) {
+		return false
+	}
+	if m.CloseIssue.Assignee != "" {
+		found := false
+		for _, a := range m.Issue.Assignees {
+			if a.GetLogin() == m.CloseIssue.Assignee {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if m.CloseIssue.Milestone != "" && m.Issue.Milestone.GetTitle() != m.CloseIssue.Milestone {
+		return false
+	}
+	return true
+}
+
+// closeActions performs the configured label and assignee actions when closing the issue
+func (m *Manager) closeActions() {
+	num := m.Issue.GetNumber()
+	if len(m.CloseActions.AddLabels) > 0 {
+		if err := m.gitClient.AddLabels(num, m.CloseActions.AddLabels...); err != nil {
+			log.Printf("could not add labels %v", err)
+		}
+	}
+	// Don't fail in case the labels aren't already set
+	_ = m.gitClient.RemoveLabels(num, m.CloseActions.RemoveLabels...)
+
+	if len(m.CloseActions.AddAssignees) > 0 {
+		if err := m.gitClient.AddAssignees(num, m.CloseActions.AddAssignees...); err != nil {
+			log.Printf("could not add assignees %v", err)
+		}
+	}
+	// Don't fail in case the assignees aren't already set
+	_ = m.gitClient.RemoveAssignees(num, m.CloseActions.RemoveAssignees...)
+}
+
 var rolloutRegex = regexp.MustCompile("\\[pull-request\\]: #(\\d+)\\s+\\[commit\\]: ([a-z0-9]+)\\s+")
 
+var managerRegex = regexp.MustCompile("\\[manager\\]: (\\S+)")
+
+// managedByOther returns true if the issue body declares a different IssueManager as its
+// owner, so managers sharing a repo never fight over each other's issues
+func (m *Manager) managedByOther(issue *github.Issue) bool {
+	matches := managerRegex.FindStringSubmatch(issue.GetBody())
+	return len(matches) == 2 && matches[1] != m.Label
+}
+
+// lockPrefix namespaces the labels used as edit leases on issues
+const lockPrefix = "lock:"
+
+func (m *Manager) lockLabel() string {
+	return lockPrefix + m.Label
+}
+
+// acquireLock takes the edit lease on the issue by adding the manager lock label so two
+// managers never edit the same issue concurrently.  When two managers race, the
+// lexically-smallest lock label wins and the loser releases its claim.
+func (m *Manager) acquireLock(issue *github.Issue) (bool, error) {
+	for _, l := range issue.Labels {
+		if strings.HasPrefix(l.GetName(), lockPrefix) && l.GetName() != m.lockLabel() {
+			return false, nil
+		}
+	}
+	if err := m.gitClient.AddLabels(issue.GetNumber(), m.lockLabel()); err != nil {
+		return false, err
+	}
+
+	// Re-read the labels to detect a race with another manager
+	fresh, _, err := m.gitHubClient.Issues.Get(context.Background(), m.Repo.Owner, m.Repo.Repo, issue.GetNumber())
+	if err != nil {
+		return false, err
+	}
+	for _, l := range fresh.Labels {
+		if strings.HasPrefix(l.GetName(), lockPrefix) && l.GetName() < m.lockLabel() {
+			// Lost the race, release our claim
+			_ = m.gitClient.RemoveLabels(issue.GetNumber(), m.lockLabel())
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// releaseLock releases the edit lease on the issue
+func (m *Manager) releaseLock(issue *github.Issue) {
+	_ = m.gitClient.RemoveLabels(issue.GetNumber(), m.lockLabel())
+}
+
 func (m *Manager) SyncToPRAndIssue() error {
 	m.PullRequest = nil
 	m.Commit = ""
@@ -148,6 +297,7 @@ func (m *Manager) SyncToPRAndIssue() error {
 	}
 	m.PullRequest = m.poller.Pr
 	m.Commit = m.poller.Commit
+	m.ChangedPaths = m.changedPaths()
 
 	issues, _, err := m.gitHubClient.Issues.ListByRepo(context.Background(), m.Repo.Owner, m.Repo.Repo, &github.IssueListByRepoOptions{
 		Labels:    []string{m.Label},
@@ -169,6 +319,9 @@ func (m *Manager) SyncToPRAndIssue() error {
 		if issue.IsPullRequest() {
 			continue
 		}
+		if m.managedByOther(issue) {
+			continue
+		}
 		if first {
 			body := issue.GetBody()
 			if !rolloutRegex.MatchString(body) {
@@ -244,11 +397,11 @@ func (m *Manager) SyncToPRAndIssue() error {
 		}
 
 		// Create a new issue for the PR
-		o := &bytes.Buffer{}
-		if err = bodyTemplate.Execute(o, m); err != nil {
+		issueBody, err := m.issueBody()
+		if err != nil {
 			return err
 		}
-		issueBody := o.String()
+		issueBody = preserveHumanSection("", issueBody)
 		title := fmt.Sprintf("Rollout #%d", m.PullRequest.GetNumber())
 		labels := append(m.OpenActions.AddLabels, m.Label)
 		ir := &github.IssueRequest{
@@ -256,8 +409,13 @@ func (m *Manager) SyncToPRAndIssue() error {
 			Labels: &labels,
 			Title:  &title,
 		}
-		if len(m.OpenActions.AddAssignees) > 0 {
-			ir.Assignees = &m.OpenActions.AddAssignees
+		assignees := m.OpenActions.AddAssignees
+		if owners := m.ownerAssignees(); len(owners) > 0 {
+			// Page the owners of the changed paths instead of the static assignee list
+			assignees = owners
+		}
+		if len(assignees) > 0 {
+			ir.Assignees = &assignees
 		}
 		if m.Issue, _, err = m.gitHubClient.Issues.Create(context.Background(), m.Repo.Owner, m.Repo.Repo, ir); err != nil {
 			log.Printf("could not open issue %v", err)
@@ -273,16 +431,39 @@ func (m *Manager) SyncToPRAndIssue() error {
 	return nil
 }
 
-var bodyTemplate = template.Must(template.New("name").Parse(`[pull-request]: #{{ .PullRequest.GetNumber}}
+// headerTemplateBody is always prepended to issue bodies so rollouts can be matched back to
+// their PRs by rolloutRegex and to the IssueManager that owns them by managerRegex
+const headerTemplateBody = `[pull-request]: #{{ .PullRequest.GetNumber}}
 [commit]: {{ .Commit }}
+[manager]: {{ .Label }}
+
+`
 
-Rollout #{{ .PullRequest.GetNumber}}
+var bodyTemplate = template.Must(template.New("name").Parse(headerTemplateBody + `Rollout #{{ .PullRequest.GetNumber}}
 
-{{ range $r := .StatusReporters -}}	
-- {{ $r.StatusIcon }} {{ $r.Name }} - *{{ $r.Status }}*{{ if not $r.Done }}{{ if $r.WaitFor}} (run after{{ range $w := $r.WaitFor }} {{ $w }}{{ end }}){{end}}{{ end }}
+{{ range $r := .StatusReporters -}}
+- {{ $r.StatusIcon }} {{ $r.Name }} - *{{ $r.Status }}*{{ if not $r.Done }}{{ if $r.WaitFor}} (run after{{ range $w := $r.WaitFor }} {{ $w }}{{ end }}){{end}}{{ end }}{{ if $r.Description }}
+  - {{ $r.Description }}{{ end }}{{ if $r.RunbookURL }}
+  - [runbook]({{ $r.RunbookURL }}){{ end }}
 {{ end -}}
 `))
 
+// issueBody renders the rollout issue body, using the configured IssueTemplate if one is set
+func (m *Manager) issueBody() (string, error) {
+	t := bodyTemplate
+	if m.IssueTemplate != "" {
+		var err error
+		if t, err = template.New("issue").Parse(headerTemplateBody + m.IssueTemplate); err != nil {
+			return "", err
+		}
+	}
+	b := &bytes.Buffer{}
+	if err := t.Execute(b, m); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
 func (m *Manager) SyncPollerPRs() error {
 	found := false
 	for !found {
@@ -0,0 +1,111 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package issues
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ownerRule maps a path prefix to the assignees responsible for it
+type ownerRule struct {
+	pattern string
+	owners  []string
+}
+
+// matches does simple prefix matching on the path, which covers directory rules
+func (r ownerRule) matches(path string) bool {
+	return strings.HasPrefix(path, strings.TrimPrefix(r.pattern, "/"))
+}
+
+// changedPaths returns the file paths changed by the PR
+func (m *Manager) changedPaths() []string {
+	files, _, err := m.gitHubClient.PullRequests.ListFiles(context.Background(), m.Repo.Owner, m.Repo.Repo,
+		m.PullRequest.GetNumber(), nil)
+	if err != nil {
+		log.Printf("could not list changed files for PR %d %v", m.PullRequest.GetNumber(), err)
+		return nil
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.GetFilename())
+	}
+	return paths
+}
+
+// ownerAssignees returns the assignees responsible for the paths changed by the PR, using
+// the PathOwners mapping first and falling back to the repo CODEOWNERS file.  Returns nil
+// if owner-based assignment is not configured or no owners match.
+func (m *Manager) ownerAssignees() []string {
+	if !m.AssignOwners && len(m.PathOwners) == 0 {
+		return nil
+	}
+
+	var rules []ownerRule
+	for path, owners := range m.PathOwners {
+		rules = append(rules, ownerRule{pattern: path, owners: owners})
+	}
+	if m.AssignOwners {
+		rules = append(rules, readCodeowners()...)
+	}
+
+	owners := sets.NewString()
+	for _, path := range m.ChangedPaths {
+		for _, rule := range rules {
+			if rule.matches(path) {
+				owners.Insert(rule.owners...)
+			}
+		}
+	}
+	return owners.List()
+}
+
+// readCodeowners parses the CODEOWNERS file from the cloned repo
+func readCodeowners() []ownerRule {
+	for _, p := range []string{"CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"} {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		return parseCodeowners(string(b))
+	}
+	return nil
+}
+
+func parseCodeowners(content string) []ownerRule {
+	var rules []ownerRule
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		var owners []string
+		for _, o := range fields[1:] {
+			owners = append(owners, strings.TrimPrefix(o, "@"))
+		}
+		rules = append(rules, ownerRule{pattern: fields[0], owners: owners})
+	}
+	return rules
+}
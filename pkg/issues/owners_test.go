@@ -0,0 +1,46 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package issues
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCodeowners(t *testing.T) {
+	rules := parseCodeowners(`# comment
+/config/prod @alice @org/oncall
+
+config/dev bob
+malformed
+`)
+
+	expected := []ownerRule{
+		{pattern: "/config/prod", owners: []string{"alice", "org/oncall"}},
+		{pattern: "config/dev", owners: []string{"bob"}},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("expected %v, got %v", expected, rules)
+	}
+
+	if !rules[0].matches("config/prod/app.yaml") {
+		t.Errorf("expected /config/prod to match config/prod/app.yaml")
+	}
+	if rules[0].matches("config/dev/app.yaml") {
+		t.Errorf("expected /config/prod to not match config/dev/app.yaml")
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package issues
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"log"
+	"regexp"
+
+	"github.com/google/go-github/github"
+)
+
+// applierStatus is one applier's rollout status, parsed from the heading of its managed
+// comment on the rollout issue
+type applierStatus struct {
+	Name   string
+	Status string
+	Icon   string
+	Done   bool
+}
+
+// applierStatusRegex matches the heading an applier renders at the top of its managed
+// status comment - "## <icon> <name> - *<status>*"
+var applierStatusRegex = regexp.MustCompile(`(?m)^## (!\[[^\]]*\]\([^)]*\) )?(.+?) - \*([^*]+)\*`)
+
+// releaseLabelPrefix namespaces the labels marking release view issues
+const releaseLabelPrefix = "release:"
+
+func (m *Manager) releaseLabel() string {
+	return releaseLabelPrefix + m.Label
+}
+
+// UpdateReleaseIssue maintains a single "Release <commit>" issue aggregating the status of
+// every applier commenting on the rollout issue - e.g. dev, staging, and prod - so a
+// release can be watched from one place.  The release issue closes once every applier
+// reports Complete.
+func (m *Manager) UpdateReleaseIssue() error {
+	if m.Issue == nil || m.Commit == "" {
+		return nil
+	}
+
+	appliers, err := m.applierStatuses()
+	if err != nil {
+		return err
+	}
+
+	release, err := m.findReleaseIssue()
+	if err != nil {
+		return err
+	}
+
+	b := &bytes.Buffer{}
+	if err := releaseTemplate.Execute(b, struct {
+		*Manager
+		Appliers []*applierStatus
+	}{m, appliers}); err != nil {
+		return err
+	}
+	body := b.String()
+
+	// Close only once every applier reports Complete
+	state := "open"
+	allDone := len(appliers) > 0
+	for _, a := range appliers {
+		if !a.Done {
+			allDone = false
+		}
+	}
+	if allDone {
+		state = "closed"
+	}
+
+	if release == nil {
+		title := "Release " + m.Commit
+		labels := []string{m.releaseLabel()}
+		release, _, err = m.gitHubClient.Issues.Create(context.Background(), m.Repo.Owner, m.Repo.Repo,
+			&github.IssueRequest{
+				Title:  &title,
+				Body:   &body,
+				Labels: &labels,
+			})
+		if err != nil {
+			return err
+		}
+		log.Printf("opened release issue %d for %s\n", release.GetNumber(), m.Commit)
+	}
+
+	// Skip the write when nothing changed to avoid clobbering concurrent edits
+	if body == release.GetBody() && state == release.GetState() {
+		return nil
+	}
+	_, _, err = m.gitHubClient.Issues.Edit(context.Background(), m.Repo.Owner, m.Repo.Repo, release.GetNumber(),
+		&github.IssueRequest{
+			State: &state,
+			Body:  &body,
+		})
+	return err
+}
+
+// applierStatuses parses the managed status comment of each applier on the rollout issue
+func (m *Manager) applierStatuses() ([]*applierStatus, error) {
+	comments, _, err := m.gitHubClient.Issues.ListComments(context.Background(), m.Repo.Owner, m.Repo.Repo,
+		m.Issue.GetNumber(), &github.IssueListCommentsOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var appliers []*applierStatus
+	for _, c := range comments {
+		matches := applierStatusRegex.FindStringSubmatch(c.GetBody())
+		if len(matches) != 4 {
+			continue
+		}
+		appliers = append(appliers, &applierStatus{
+			Icon:   matches[1],
+			Name:   matches[2],
+			Status: matches[3],
+			Done:   matches[3] == "Complete",
+		})
+	}
+	return appliers, nil
+}
+
+// findReleaseIssue returns the release issue for the current commit, closing release
+// issues left open for earlier commits.  Returns nil if the issue does not exist yet.
+func (m *Manager) findReleaseIssue() (*github.Issue, error) {
+	issues, _, err := m.gitHubClient.Issues.ListByRepo(context.Background(), m.Repo.Owner, m.Repo.Repo,
+		&github.IssueListByRepoOptions{
+			Labels:    []string{m.releaseLabel()},
+			State:     "all",
+			Sort:      "created",
+			Direction: "desc",
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var release *github.Issue
+	for _, issue := range issues {
+		if issue.IsPullRequest() {
+			continue
+		}
+		matches := rolloutRegex.FindStringSubmatch(issue.GetBody())
+		if len(matches) == 3 && matches[2] == m.Commit {
+			release = issue
+			continue
+		}
+		if issue.GetState() == "open" {
+			closed := "closed"
+			_, _, err := m.gitHubClient.Issues.Edit(context.Background(), m.Repo.Owner, m.Repo.Repo, issue.GetNumber(),
+				&github.IssueRequest{
+					State: &closed,
+				})
+			if err != nil {
+				log.Printf("could not close release issue %v %v", issue.GetNumber(), err)
+			}
+		}
+	}
+	return release, nil
+}
+
+var releaseTemplate = template.Must(template.New("release").Parse(headerTemplateBody +
+	`Release {{ .Commit }} for rollout #{{ .PullRequest.GetNumber }} (#{{ .Issue.GetNumber }})
+
+{{ range $a := .Appliers -}}
+- {{ $a.Icon }}{{ $a.Name }} - *{{ $a.Status }}*
+{{ end -}}
+`))
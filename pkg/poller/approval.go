@@ -0,0 +1,102 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// checkApproval verifies the merged PR was approved by a member of the configured
+// GitHub team before applying, so protected clusters only receive changes signed off by
+// e.g. the platform oncall.  Failures are commented on the rollout issue naming the
+// missing approval.
+func (p *Poller) checkApproval() error {
+	if p.ApprovalTeam == "" || p.Pr == nil {
+		return nil
+	}
+
+	num := p.Pr.GetNumber()
+	reviews, _, err := p.GitClient.PullRequests.ListReviews(context.TODO(), p.Owner, p.Repo, num, nil)
+	if err != nil {
+		return fmt.Errorf("could not list reviews for PR %d: %v", num, err)
+	}
+
+	var approvers []string
+	for _, r := range reviews {
+		if strings.EqualFold(r.GetState(), "approved") {
+			approvers = append(approvers, r.GetUser().GetLogin())
+		}
+	}
+	for _, u := range approvers {
+		member, err := p.teamMember(u)
+		if err != nil {
+			return err
+		}
+		if member {
+			p.unapprovedPR = 0
+			return nil
+		}
+	}
+
+	// Each PR is only annotated once - the check re-runs every poll cycle
+	if p.unapprovedPR != num {
+		msg := fmt.Sprintf("Not applying #%d: no approval from a member of team %s/%s.  "+
+			"Approvals: %s.", num, p.Owner, p.ApprovalTeam, approverList(approvers))
+		if err := p.GitClient.Comment(p.IssueNum, msg); err != nil {
+			log.Printf("could not comment missing approval: %v", err)
+		} else {
+			p.unapprovedPR = num
+		}
+	}
+	return fmt.Errorf("PR %d is missing an approval from team %s", num, p.ApprovalTeam)
+}
+
+// approverList formats the approving reviewers, or notes there were none
+func approverList(approvers []string) string {
+	if len(approvers) == 0 {
+		return "none"
+	}
+	return strings.Join(approvers, ", ")
+}
+
+// teamMember returns true if the user is a member of the approval team, resolving the
+// team slug to its id on first use
+func (p *Poller) teamMember(user string) (bool, error) {
+	if p.approvalTeamID == 0 {
+		teams, _, err := p.GitClient.Organizations.ListTeams(context.TODO(), p.Owner, nil)
+		if err != nil {
+			return false, fmt.Errorf("could not list teams for %s: %v", p.Owner, err)
+		}
+		for _, t := range teams {
+			if t.GetSlug() == p.ApprovalTeam {
+				p.approvalTeamID = t.GetID()
+			}
+		}
+		if p.approvalTeamID == 0 {
+			return false, fmt.Errorf("no team %s in %s", p.ApprovalTeam, p.Owner)
+		}
+	}
+
+	member, _, err := p.GitClient.Organizations.IsTeamMember(context.TODO(), p.approvalTeamID, user)
+	if err != nil {
+		return false, fmt.Errorf("could not check team membership for %s: %v", user, err)
+	}
+	return member, nil
+}
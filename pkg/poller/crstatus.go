@@ -0,0 +1,115 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pwittrock/continuous-apply/pkg/apis"
+	applyv1beta1 "github.com/pwittrock/continuous-apply/pkg/apis/apply/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// syncStatus records poll and rollout timestamps on the ContinuousApply status so
+// `kubectl get` reveals a silently stuck poller - e.g. an expired token - without
+// reading pod logs.  appliedCommit is set when a rollout just finished.  The CR shares
+// the applier's name and the progress namespace.
+func (p *Poller) syncStatus(appliedCommit string) {
+	if p.ProgressNamespace == "" {
+		return
+	}
+	if err := p.statusClient(); err != nil {
+		log.Printf("could not build status client: %v", err)
+		return
+	}
+
+	instance := &applyv1beta1.ContinuousApply{}
+	name := types.NamespacedName{Namespace: p.ProgressNamespace, Name: p.Name}
+	if err := p.crClient.Get(context.TODO(), name, instance); err != nil {
+		// The applier may run without a ContinuousApply, e.g. started by hand
+		if !errors.IsNotFound(err) {
+			log.Printf("could not get %s for status: %v", name, err)
+		}
+		return
+	}
+
+	now := metav1.Now()
+	instance.Status.LastPollTime = &now
+	next := metav1.NewTime(now.Add(30 * time.Second))
+	instance.Status.NextScheduledSync = &next
+	instance.Status.PollError = ""
+	if appliedCommit != "" {
+		instance.Status.LastAppliedTime = &now
+		instance.Status.LastAppliedCommit = appliedCommit
+	}
+	if err := p.crClient.Update(context.TODO(), instance); err != nil {
+		log.Printf("could not update %s status: %v", name, err)
+	}
+}
+
+// setPollError surfaces a GitHub access problem on the ContinuousApply status so
+// `kubectl get` explains why polling is failing.  It is cleared by the next successful
+// poll.
+func (p *Poller) setPollError(msg string) {
+	if p.ProgressNamespace == "" {
+		return
+	}
+	if err := p.statusClient(); err != nil {
+		log.Printf("could not build status client: %v", err)
+		return
+	}
+
+	instance := &applyv1beta1.ContinuousApply{}
+	name := types.NamespacedName{Namespace: p.ProgressNamespace, Name: p.Name}
+	if err := p.crClient.Get(context.TODO(), name, instance); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Printf("could not get %s for status: %v", name, err)
+		}
+		return
+	}
+	if instance.Status.PollError == msg {
+		return
+	}
+
+	instance.Status.PollError = msg
+	if err := p.crClient.Update(context.TODO(), instance); err != nil {
+		log.Printf("could not update %s status: %v", name, err)
+	}
+}
+
+// statusClient lazily builds a client with the apply types registered.  The applier's
+// K8sClient can't be reused because it is rebuilt for each rollout with the core scheme.
+func (p *Poller) statusClient() error {
+	if p.crClient != nil {
+		return nil
+	}
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		return err
+	}
+	cfg, err := p.restConfig()
+	if err != nil {
+		return err
+	}
+	p.crClient, err = client.New(cfg, client.Options{})
+	return err
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poller
+
+import (
+	"expvar"
+	"fmt"
+	"strconv"
+
+	"github.com/google/go-github/github"
+)
+
+// githubErrors counts GitHub access failures by status code on /debug/vars so a stuck
+// poller can be alerted on
+var githubErrors = expvar.NewMap("github_errors")
+
+// accessProblem returns an actionable description when err is a GitHub 401, 403, or 404 -
+// a bad token, missing scopes, or a renamed repo - and counts it in the github_errors
+// expvar map.  Transient errors, including rate limits, return empty and are retried as
+// before.
+func (p *Poller) accessProblem(err error) string {
+	if _, ok := err.(*github.RateLimitError); ok {
+		// Rate limits clear on their own
+		return ""
+	}
+	resp, ok := err.(*github.ErrorResponse)
+	if !ok || resp.Response == nil {
+		return ""
+	}
+
+	code := resp.Response.StatusCode
+	switch code {
+	case 401:
+		githubErrors.Add(strconv.Itoa(code), 1)
+		return "GitHub rejected the access token (401) - the token is invalid or expired"
+	case 403:
+		githubErrors.Add(strconv.Itoa(code), 1)
+		return fmt.Sprintf("GitHub denied access to %s/%s (403) - the token is missing scopes "+
+			"or access was revoked", p.Owner, p.Repo)
+	case 404:
+		githubErrors.Add(strconv.Itoa(code), 1)
+		return fmt.Sprintf("GitHub could not find %s/%s (404) - the repo was renamed, made "+
+			"private, or the token can't see it", p.Owner, p.Repo)
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poller
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// heartbeatComment is the managed comment name for the heartbeat
+const heartbeatComment = "heartbeat"
+
+// maxSkippedMerges bounds the skip history shown in the heartbeat comment
+const maxSkippedMerges = 10
+
+// recordSkip remembers that a merged PR was rejected by the match filters so the
+// heartbeat comment can explain it
+func (p *Poller) recordSkip(num int, reason string) {
+	entry := fmt.Sprintf("#%d - %s", num, reason)
+	for _, s := range p.skippedMerges {
+		if s == entry {
+			return
+		}
+	}
+	p.skippedMerges = append(p.skippedMerges, entry)
+	if len(p.skippedMerges) > maxSkippedMerges {
+		p.skippedMerges = p.skippedMerges[len(p.skippedMerges)-maxSkippedMerges:]
+	}
+}
+
+// heartbeat posts or updates a comment on the configured issue describing what the
+// poller is matching on and which recent merges were skipped.  Without it "no matching
+// PRs found" only reaches the pod log and admins can't tell the applier is idle
+// because of a label or milestone mismatch.
+func (p *Poller) heartbeat(cause error) {
+	if p.HeartbeatIssue == 0 {
+		return
+	}
+	interval := p.HeartbeatInterval
+	if interval == 0 {
+		interval = time.Hour
+	}
+	if time.Since(p.lastHeartbeat) < interval {
+		return
+	}
+
+	buff := &bytes.Buffer{}
+	fmt.Fprintf(buff, "## Applier %s is idle\n\n%v as of %s.\n\nMatching on:\n",
+		p.Name, cause, time.Now().Format(time.RFC1123))
+	filtered := false
+	if len(p.MatchLabels) > 0 {
+		fmt.Fprintf(buff, "- labels: %s\n", strings.Join(p.MatchLabels, ", "))
+		filtered = true
+	}
+	if p.MatchAssignee != "" {
+		fmt.Fprintf(buff, "- assignee: %s\n", p.MatchAssignee)
+		filtered = true
+	}
+	if p.MatchMilestone != "" {
+		fmt.Fprintf(buff, "- milestone: %s\n", p.MatchMilestone)
+		filtered = true
+	}
+	if p.MatchState != "" {
+		fmt.Fprintf(buff, "- state: %s\n", p.MatchState)
+		filtered = true
+	}
+	if !filtered {
+		fmt.Fprintf(buff, "- any merged PR\n")
+	}
+	if len(p.skippedMerges) > 0 {
+		fmt.Fprintf(buff, "\nRecently skipped merges:\n")
+		for _, s := range p.skippedMerges {
+			fmt.Fprintf(buff, "- %s\n", s)
+		}
+	}
+
+	comment, err := p.GitClient.GetComment(heartbeatComment, p.User, p.HeartbeatIssue)
+	if err != nil || comment == nil {
+		log.Printf("could not get heartbeat comment: %v", err)
+		return
+	}
+	body := buff.String()
+	comment.Body = &body
+	if _, err := p.GitClient.UpdateComment(comment, heartbeatComment, p.User, p.HeartbeatIssue); err != nil {
+		log.Printf("could not update heartbeat comment: %v", err)
+		return
+	}
+	p.lastHeartbeat = time.Now()
+}
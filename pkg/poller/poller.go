@@ -25,15 +25,21 @@ import (
 	"time"
 
 	"os/exec"
+	"os/signal"
+	"syscall"
 
 	"strings"
 
 	"os"
 
 	"github.com/google/go-github/github"
+	applyv1beta1 "github.com/pwittrock/continuous-apply/pkg/apis/apply/v1beta1"
 	"github.com/pwittrock/continuous-apply/pkg/applier"
 	"github.com/pwittrock/continuous-apply/pkg/git"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 )
@@ -51,6 +57,47 @@ type Poller struct {
 	// Applier to use
 	applier.Applier
 
+	// WorkDir is the directory to clone the repo into.  Empty uses the current directory.
+	WorkDir string
+
+	// GitHubDryRun logs GitHub writes instead of performing them
+	GitHubDryRun bool
+
+	// CommitterName and CommitterEmail configure the git identity used for write operations
+	CommitterName  string
+	CommitterEmail string
+
+	// Proxy is an HTTP(S) proxy URL used for git and GitHub API traffic
+	Proxy string
+
+	// CABundle is a PEM file of additional CAs to trust for git and GitHub API traffic
+	CABundle string
+
+	// SyncStrategy controls how aggressively the git work tree is cleaned when syncing
+	SyncStrategy string
+
+	// Submodules initializes and updates git submodules when syncing
+	Submodules bool
+
+	// LFS pulls git-lfs content when syncing
+	LFS bool
+
+	// SparseCheckout limits the checkout to the apply targets plus the kustomize bases they
+	// reference, reducing clone time and disk usage in monorepos
+	SparseCheckout bool
+
+	// RequestInterval is the minimum time between GitHub API requests
+	RequestInterval time.Duration
+
+	// RequestTimeout bounds each GitHub API request
+	RequestTimeout time.Duration
+
+	// MaxRetries retries failed GitHub API reads before giving up
+	MaxRetries int
+
+	// APIEndpoint is the base URL for GitHub API requests, e.g. a caching proxy
+	APIEndpoint string
+
 	// MatchLabels filters Issues/PRs to rollout by labels
 	MatchLabels []string
 
@@ -68,6 +115,88 @@ type Poller struct {
 
 	// SkipSetState is the state to set when skipping an issue or Pr
 	SkipSetState string
+
+	// MaxFailures stops auto-applying a commit after this many consecutive failed rollouts.
+	// Zero retries forever.
+	MaxFailures int
+
+	// FailureLabel is the label set on the issue when MaxFailures is reached
+	FailureLabel string
+
+	// NotifyCommand is a shell command run when MaxFailures is reached
+	NotifyCommand string
+
+	// PrioritizeReverts labels rollout issues for revert PRs with the revert label and
+	// rolls them out ahead of other queued merges - reverts are the emergency brake
+	PrioritizeReverts bool
+
+	// RevertsSkipGates lets revert PRs bypass the match filters so emergency reverts don't
+	// wait on approval labels
+	RevertsSkipGates bool
+
+	// PriorityLabel makes merged PRs carrying the label - e.g. priority/critical - jump
+	// the rollout queue
+	PriorityLabel string
+
+	// PreemptionPolicy controls whether a priority PR may preempt an in-flight rollout.
+	// preempt interrupts the rollout; empty waits for it to finish.
+	PreemptionPolicy string
+
+	// Freeze stops new rollouts from starting.  Queued rollouts are annotated and resume
+	// when the freeze lifts or an admin comments /apply force.
+	Freeze bool
+
+	// FreezeLabel freezes all rollouts while any open issue carries the label - e.g.
+	// deploy-freeze on a pinned issue
+	FreezeLabel string
+
+	// HeartbeatIssue is an issue to post an idle heartbeat comment on when no merges
+	// match the poller's filters, so admins can see why the applier is idle without
+	// reading the pod log.  Zero disables the heartbeat.
+	HeartbeatIssue int
+
+	// HeartbeatInterval is the minimum time between heartbeat comment updates.
+	// Zero defaults to an hour.
+	HeartbeatInterval time.Duration
+
+	// QueueIssue is a pinned issue to mirror the deploy queue onto so developers can see
+	// where their change is.  Zero disables the queue comment.
+	QueueIssue int
+
+	// NotifyPR posts a short outcome comment with a permalink to the rollout status on
+	// the originating PR after each rollout
+	NotifyPR bool
+
+	// ApprovalTeam is a GitHub team slug - e.g. platform-oncall - a member of which must
+	// have approved the merged PR before it is applied.  Empty applies without the check.
+	ApprovalTeam string
+
+	// approvalTeamID is the resolved id of ApprovalTeam, looked up on first use
+	approvalTeamID int64
+
+	// frozenIssue is the issue already annotated as frozen, so it is only commented once
+	frozenIssue int
+
+	// unapprovedPR is the PR already annotated as missing an approval, so it is only
+	// commented once per poll cycle
+	unapprovedPR int
+
+	// lastHeartbeat is when the heartbeat comment was last updated
+	lastHeartbeat time.Time
+
+	// skippedMerges are the most recent merges rejected by the match filters, shown in
+	// the heartbeat comment
+	skippedMerges []string
+
+	// lastQueue is the queue as last published, so unchanged queues aren't re-written
+	lastQueue []applyv1beta1.QueuedRollout
+
+	// crClient reads and writes the ContinuousApply status, lazily built with the apply
+	// types registered
+	crClient client.Client
+
+	// ctx cancels in-flight rollouts when the poller is shutting down
+	ctx context.Context
 }
 
 var rolloutRegex = regexp.MustCompile("\\[pull-request\\]: #(\\d+)\\s+\\[commit\\]: ([a-z0-9]+)\\s+")
@@ -84,6 +213,7 @@ func (p *Poller) SyncIssues() (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	p.syncStatus("")
 
 	if len(issues) == 0 {
 		return false, fmt.Errorf("no matching issues found")
@@ -169,8 +299,30 @@ func (p *Poller) SyncPRs() (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("could not retrieve git log %v", err)
 	}
+	p.syncStatus("")
 
-	for _, l := range strings.Split(string(o), "\n") {
+	// Reverts are the emergency brake - roll them out ahead of anything else queued
+	if p.PrioritizeReverts {
+		if found, err := p.findRevert(string(o)); err != nil {
+			return false, err
+		} else if found {
+			return true, nil
+		}
+	}
+
+	// PRs carrying the priority label jump the queue
+	if p.PriorityLabel != "" {
+		if found, err := p.findPriority(string(o)); err != nil {
+			return false, err
+		} else if found {
+			return true, nil
+		}
+	}
+
+	applied := p.Commit
+	var queue []applyv1beta1.QueuedRollout
+	lines := strings.Split(string(o), "\n")
+	for i, l := range lines {
 		matches := prRegex.FindStringSubmatch(string(l))
 		if len(matches) < 3 {
 			continue
@@ -182,7 +334,8 @@ func (p *Poller) SyncPRs() (bool, error) {
 		}
 
 		// No new issues
-		if commit == p.Commit {
+		if commit == applied {
+			p.publishQueue(queue)
 			return true, nil
 		}
 
@@ -194,80 +347,450 @@ func (p *Poller) SyncPRs() (bool, error) {
 		}
 
 		// Filter PRs
-		if len(p.MatchLabels) > 0 {
-			labels := sets.String{}
-			for _, l := range issue.Labels {
-				labels.Insert(l.GetName())
-			}
-			match := true
-			for _, l := range p.MatchLabels {
-				if !labels.Has(l) {
-					match = false
-					fmt.Printf("label %s missing from %v\n", l, labels.List())
-					break
-				}
-			}
-			if !match {
-				continue
-			}
+		if reason := p.matchFailure(issue); reason != "" {
+			fmt.Printf("%s\n", reason)
+			p.recordSkip(num, reason)
+			queue = append(queue, applyv1beta1.QueuedRollout{PullRequest: num, Commit: commit, Note: reason})
+			continue
 		}
-		if p.MatchAssignee != "" {
-			found := false
-			for _, a := range issue.Assignees {
-				if a.GetLogin() == p.MatchAssignee {
-					found = true
-				}
-			}
-			if !found {
-				fmt.Printf("assignee %s not found\n", p.MatchAssignee)
-				continue
-			}
+
+		p.Issue = issue
+		pr, _, err := p.GitClient.PullRequests.Get(context.TODO(), p.Owner, p.Repo, num)
+		if err != nil {
+			return false, err
 		}
-		if p.MatchMilestone != "" {
-			if issue.Milestone.GetTitle() != p.MatchMilestone {
-				fmt.Printf("milestone %s does not match\n", issue.Milestone.GetTitle())
-				continue
+		p.Pr = pr
+		p.Commit = commit
+		p.IssueNum = num
+		queue = append(queue, applyv1beta1.QueuedRollout{PullRequest: num, Commit: commit, Note: "rolling out next"})
+		// Older unapplied merges ride along - applying the newest commit includes them
+		queue = append(queue, p.ridealongs(lines[i+1:], applied, num)...)
+		p.publishQueue(queue)
+		return true, nil
+	}
+	return false, fmt.Errorf("no matching PRs found")
+}
+
+// ridealongs returns queue entries for the merges between the chosen commit and the
+// applied commit, which are included in the chosen rollout
+func (p *Poller) ridealongs(lines []string, applied string, chosen int) []applyv1beta1.QueuedRollout {
+	var queue []applyv1beta1.QueuedRollout
+	for _, l := range lines {
+		matches := prRegex.FindStringSubmatch(l)
+		if len(matches) < 3 {
+			continue
+		}
+		if matches[1] == applied {
+			break
+		}
+		num, err := strconv.Atoi(matches[2])
+		if err != nil {
+			continue
+		}
+		queue = append(queue, applyv1beta1.QueuedRollout{
+			PullRequest: num,
+			Commit:      matches[1],
+			Note:        fmt.Sprintf("included in #%d", chosen),
+		})
+	}
+	return queue
+}
+
+// matchesFilters returns true if the issue or PR passes the configured match filters
+func (p *Poller) matchesFilters(issue *github.Issue) bool {
+	if reason := p.matchFailure(issue); reason != "" {
+		fmt.Printf("%s\n", reason)
+		return false
+	}
+	return true
+}
+
+// matchFailure returns why the issue or PR fails the configured match filters, or empty
+// if it passes
+func (p *Poller) matchFailure(issue *github.Issue) string {
+	if len(p.MatchLabels) > 0 {
+		labels := sets.String{}
+		for _, l := range issue.Labels {
+			labels.Insert(l.GetName())
+		}
+		for _, l := range p.MatchLabels {
+			if !labels.Has(l) {
+				return fmt.Sprintf("label %s missing from %v", l, labels.List())
 			}
 		}
-		if p.MatchState != "" {
-			if issue.GetState() != p.MatchState && p.MatchState != "all" {
-				fmt.Printf("state %s does not match\n", issue.GetState())
-				continue
+	}
+	if p.MatchAssignee != "" {
+		found := false
+		for _, a := range issue.Assignees {
+			if a.GetLogin() == p.MatchAssignee {
+				found = true
 			}
 		}
+		if !found {
+			return fmt.Sprintf("assignee %s not found", p.MatchAssignee)
+		}
+	}
+	if p.MatchMilestone != "" {
+		if issue.Milestone.GetTitle() != p.MatchMilestone {
+			return fmt.Sprintf("milestone %s does not match", issue.Milestone.GetTitle())
+		}
+	}
+	if p.MatchState != "" {
+		if issue.GetState() != p.MatchState && p.MatchState != "all" {
+			return fmt.Sprintf("state %s does not match", issue.GetState())
+		}
+	}
+	return ""
+}
+
+// isRevertPR returns true if the PR reverts a previously merged PR.  GitHub-generated
+// revert PRs are titled `Revert "..."` and branched revert-<num>-.
+func isRevertPR(pr *github.PullRequest) bool {
+	if strings.HasPrefix(pr.GetTitle(), `Revert "`) {
+		return true
+	}
+	return strings.HasPrefix(pr.Head.GetRef(), "revert-")
+}
+
+// findRevert scans the merges newer than the applied commit for a revert PR so reverts
+// jump ahead of other queued rollouts.  Reverts optionally bypass the match filters.
+func (p *Poller) findRevert(gitLog string) (bool, error) {
+	for _, l := range strings.Split(gitLog, "\n") {
+		matches := prRegex.FindStringSubmatch(l)
+		if len(matches) < 3 {
+			continue
+		}
+		commit := matches[1]
+		if commit == p.Commit {
+			return false, nil
+		}
+		num, err := strconv.Atoi(matches[2])
+		if err != nil {
+			continue
+		}
+
+		pr, _, err := p.GitClient.PullRequests.Get(context.TODO(), p.Owner, p.Repo, num)
+		if err != nil {
+			return false, err
+		}
+		if !isRevertPR(pr) {
+			continue
+		}
+		issue, _, err := p.GitClient.Issues.Get(context.Background(), p.Owner, p.Repo, num)
+		if err != nil {
+			return false, err
+		}
+		if !p.RevertsSkipGates && !p.matchesFilters(issue) {
+			continue
+		}
 
+		log.Printf("prioritizing revert PR %d at %s\n", num, commit)
+		if err := p.GitClient.AddLabels(num, "revert"); err != nil {
+			log.Printf("%v", err)
+		}
 		p.Issue = issue
+		p.Pr = pr
+		p.Commit = commit
+		p.IssueNum = num
+		p.publishQueue([]applyv1beta1.QueuedRollout{
+			{PullRequest: num, Commit: commit, Note: "revert - jumped the queue"}})
+		return true, nil
+	}
+	return false, nil
+}
+
+// hasLabel returns true if the issue carries the label
+func hasLabel(issue *github.Issue, label string) bool {
+	for _, l := range issue.Labels {
+		if l.GetName() == label {
+			return true
+		}
+	}
+	return false
+}
+
+// findPriority scans the merges newer than the applied commit for a PR carrying the
+// priority label so critical fixes jump the rollout queue
+func (p *Poller) findPriority(gitLog string) (bool, error) {
+	for _, l := range strings.Split(gitLog, "\n") {
+		matches := prRegex.FindStringSubmatch(l)
+		if len(matches) < 3 {
+			continue
+		}
+		commit := matches[1]
+		if commit == p.Commit {
+			return false, nil
+		}
+		num, err := strconv.Atoi(matches[2])
+		if err != nil {
+			continue
+		}
+
+		issue, _, err := p.GitClient.Issues.Get(context.Background(), p.Owner, p.Repo, num)
+		if err != nil {
+			return false, err
+		}
+		if !hasLabel(issue, p.PriorityLabel) || !p.matchesFilters(issue) {
+			continue
+		}
 		pr, _, err := p.GitClient.PullRequests.Get(context.TODO(), p.Owner, p.Repo, num)
 		if err != nil {
 			return false, err
 		}
+
+		log.Printf("prioritizing %s PR %d at %s\n", p.PriorityLabel, num, commit)
+		p.Issue = issue
 		p.Pr = pr
 		p.Commit = commit
 		p.IssueNum = num
+		p.publishQueue([]applyv1beta1.QueuedRollout{
+			{PullRequest: num, Commit: commit, Note: "priority - jumped the queue"}})
 		return true, nil
 	}
-	return false, fmt.Errorf("no matching PRs found")
+	return false, nil
+}
+
+// watchPreemption interrupts the in-flight rollout if a PR carrying the priority label
+// merges while it is rolling out, recording the decision on both issues
+func (p *Poller) watchPreemption(ctx context.Context, cancel context.CancelFunc) {
+	t := time.NewTicker(30 * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		_ = exec.Command("git", "fetch", "origin").Run()
+		o, err := exec.Command("git", "log", "origin/master", "--merges", "--pretty=oneline").CombinedOutput()
+		if err != nil {
+			continue
+		}
+		num, found := p.priorityMergedSince(string(o))
+		if !found {
+			continue
+		}
+
+		log.Printf("preempting rollout of #%d for priority PR #%d\n", p.IssueNum, num)
+		_ = p.GitClient.Comment(p.IssueNum, fmt.Sprintf(
+			"rollout preempted by #%d (%s)", num, p.PriorityLabel))
+		_ = p.GitClient.Comment(num, fmt.Sprintf(
+			"preempting the in-flight rollout of #%d", p.IssueNum))
+		cancel()
+		return
+	}
+}
+
+// priorityMergedSince returns the first PR newer than the applied commit that carries the
+// priority label
+func (p *Poller) priorityMergedSince(gitLog string) (int, bool) {
+	for _, l := range strings.Split(gitLog, "\n") {
+		matches := prRegex.FindStringSubmatch(l)
+		if len(matches) < 3 {
+			continue
+		}
+		if matches[1] == p.Commit {
+			return 0, false
+		}
+		num, err := strconv.Atoi(matches[2])
+		if err != nil {
+			continue
+		}
+		issue, _, err := p.GitClient.Issues.Get(context.Background(), p.Owner, p.Repo, num)
+		if err != nil {
+			continue
+		}
+		if hasLabel(issue, p.PriorityLabel) {
+			return num, true
+		}
+	}
+	return 0, false
+}
+
+var forceRegex = regexp.MustCompile(`(?m)^/apply force\s*$`)
+
+// frozen returns true if a repo-level freeze is in effect and no admin has overridden it
+// with /apply force on the rollout issue
+func (p *Poller) frozen() (bool, error) {
+	frozen := p.Freeze
+	if !frozen && p.FreezeLabel != "" {
+		issues, _, err := p.GitClient.Issues.ListByRepo(context.TODO(), p.Owner, p.Repo,
+			&github.IssueListByRepoOptions{State: "open", Labels: []string{p.FreezeLabel}})
+		if err != nil {
+			return false, err
+		}
+		frozen = len(issues) > 0
+	}
+	if !frozen {
+		return false, nil
+	}
+
+	force, err := p.forceRequested()
+	if err != nil {
+		return false, err
+	}
+	return !force, nil
+}
+
+// forceRequested returns true if a repo collaborator commented /apply force on the rollout
+// issue, overriding the freeze
+func (p *Poller) forceRequested() (bool, error) {
+	if p.Issue == nil {
+		return false, nil
+	}
+	comments, _, err := p.GitClient.Issues.ListComments(context.TODO(), p.Owner, p.Repo,
+		p.Issue.GetNumber(), nil)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range comments {
+		if !forceRegex.MatchString(c.GetBody()) {
+			continue
+		}
+		admin, _, err := p.GitClient.Repositories.IsCollaborator(context.TODO(), p.Owner,
+			p.Repo, c.User.GetLogin())
+		if err != nil {
+			return false, err
+		}
+		if admin {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// annotateFrozen records on the rollout issue that it is queued behind the freeze.  Each
+// issue is only annotated once.
+func (p *Poller) annotateFrozen() {
+	if p.Issue == nil || p.frozenIssue == p.Issue.GetNumber() {
+		return
+	}
+	log.Printf("rollout of #%d is frozen\n", p.Issue.GetNumber())
+	if err := p.GitClient.Comment(p.Issue.GetNumber(),
+		"rollout is frozen and queued; it will start when the freeze lifts or an admin comments `/apply force`"); err != nil {
+		log.Printf("%v", err)
+		return
+	}
+	p.frozenIssue = p.Issue.GetNumber()
+}
+
+var pinRegex = regexp.MustCompile(`(?m)^/pin-image\s+(\S+)=(\S+)\s*$`)
+
+// imagePins parses /pin-image name=tag directives from comments on the PR being rolled out
+func (p *Poller) imagePins() (map[string]string, error) {
+	pins := map[string]string{}
+	if p.Pr == nil {
+		return pins, nil
+	}
+	comments, _, err := p.GitClient.Issues.ListComments(context.TODO(), p.Owner, p.Repo, p.Pr.GetNumber(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range comments {
+		for _, m := range pinRegex.FindAllStringSubmatch(c.GetBody(), -1) {
+			pins[m[1]] = m[2]
+		}
+	}
+	return pins, nil
+}
+
+// failRollout marks the issue as needing attention and fires the notification hook after the
+// error budget for a commit is exhausted
+func (p *Poller) failRollout() {
+	log.Printf("rollout of %s failed %d times, halting until a new commit appears\n", p.Commit, p.MaxFailures)
+
+	if p.FailureLabel != "" && p.Issue != nil {
+		if err := p.GitClient.AddLabels(int(p.Issue.GetNumber()), p.FailureLabel); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+
+	if p.NotifyCommand != "" {
+		cmd := exec.Command("sh", "-c", p.NotifyCommand)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Printf("notify command failed %v", err)
+		}
+	}
+}
+
+// ConfigureGitClient copies the git and GitHub client settings onto a newly created manager
+func (p *Poller) ConfigureGitClient(m *git.GitManager) error {
+	m.Audit = p.Audit
+	m.WorkDir = p.WorkDir
+	m.DryRun = p.GitHubDryRun
+	m.CommitterName = p.CommitterName
+	m.CommitterEmail = p.CommitterEmail
+	m.Proxy = p.Proxy
+	m.CABundle = p.CABundle
+	m.SyncStrategy = p.SyncStrategy
+	m.Submodules = p.Submodules
+	m.LFS = p.LFS
+	if p.SparseCheckout {
+		m.SparsePaths = p.ApplyTargets
+	}
+	m.RequestInterval = p.RequestInterval
+	m.RequestTimeout = p.RequestTimeout
+	m.MaxRetries = p.MaxRetries
+	m.APIEndpoint = p.APIEndpoint
+	return m.ConfigureTransport()
+}
+
+// restConfig returns the rest.Config driving both status checks and applies.  An explicit
+// kubeconfig and context take precedence over the in-cluster config.
+func (p *Poller) restConfig() (*rest.Config, error) {
+	if p.Kubeconfig != "" {
+		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: p.Kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: p.Context}).ClientConfig()
+	}
+	return config.GetConfig()
 }
 
 func (p *Poller) Apply() error {
 	var err error
-	cfg, err := config.GetConfig()
+	cfg, err := p.restConfig()
 	if err != nil {
 		return err
 	}
 
+	// Collect pinned images from the PR before applying
+	if p.Applier.ImagePins, err = p.imagePins(); err != nil {
+		return err
+	}
+
 	p.Applier.K8sClient, err = client.New(cfg, client.Options{})
 	if err != nil {
 		return err
 	}
+	p.Applier.DynamicClient = dynamic.NewDynamicClientPool(cfg)
 
 	if p.Applier.GitClient, err = git.NewManager(p.Owner, p.Repo, p.Commit); err != nil {
 		return err
 	}
+	if err := p.ConfigureGitClient(p.Applier.GitClient); err != nil {
+		return err
+	}
 	p.Applier.IssueNum = int(p.Issue.GetNumber())
 	if err != nil {
 		return err
 	}
+	if p.ctx != nil {
+		ctx := p.ctx
+
+		// Let a priority PR merged mid-rollout interrupt this one per the preemption policy
+		if p.PriorityLabel != "" && p.PreemptionPolicy == "preempt" {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+			defer cancel()
+			go p.watchPreemption(ctx, cancel)
+		}
+
+		_, err = p.Applier.RunContext(ctx, "")
+		return err
+	}
 	return p.Applier.Run()
 }
 
@@ -276,8 +799,28 @@ func (p *Poller) Run() error {
 		return err
 	}
 
+	// Interrupt in-flight rollouts on SIGTERM so the applier stops taking new objects,
+	// marks the comment interrupted, and exits within the termination grace period
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-stop
+		log.Printf("received termination signal, interrupting rollout\n")
+		cancel()
+	}()
+	p.ctx = ctx
+
 	fmt.Printf("running")
+	failures := 0
+	failedCommit := ""
+	haltedCommit := ""
 	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		// Find the commit and issue
 		var newCommit bool
 		var err error
@@ -287,6 +830,11 @@ func (p *Poller) Run() error {
 			newCommit, err = p.SyncPRs()
 			if err != nil {
 				log.Printf("%v", err)
+				if msg := p.accessProblem(err); msg != "" {
+					log.Printf("%s", msg)
+					p.setPollError(msg)
+				}
+				p.heartbeat(err)
 				time.Sleep(30 * time.Second)
 				continue
 			}
@@ -295,6 +843,11 @@ func (p *Poller) Run() error {
 			newCommit, err = p.SyncIssues()
 			if err != nil {
 				log.Printf("%v", err)
+				if msg := p.accessProblem(err); msg != "" {
+					log.Printf("%s", msg)
+					p.setPollError(msg)
+				}
+				p.heartbeat(err)
 				time.Sleep(30 * time.Second)
 				continue
 			}
@@ -305,9 +858,56 @@ func (p *Poller) Run() error {
 			continue
 		}
 
+		// A repo-level freeze stops new rollouts from starting unless an admin overrides it
+		if frozen, err := p.frozen(); err != nil {
+			log.Printf("%v", err)
+			time.Sleep(30 * time.Second)
+			continue
+		} else if frozen {
+			p.annotateFrozen()
+			time.Sleep(30 * time.Second)
+			continue
+		}
+
+		// Don't retry a commit that has exhausted the error budget until a new one appears
+		if haltedCommit != "" {
+			if p.Commit == haltedCommit {
+				time.Sleep(30 * time.Second)
+				continue
+			}
+			haltedCommit = ""
+			failures = 0
+		}
+
+		// Protected clusters only receive changes signed off by the approval team
+		if err := p.checkApproval(); err != nil {
+			log.Printf("%v", err)
+			time.Sleep(30 * time.Second)
+			continue
+		}
+
 		// Apply the most recent issue
 		if err := p.Apply(); err != nil {
+			// Exit cleanly if the rollout was interrupted by a termination signal
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			log.Printf("%v", err)
+			p.notifyPR(err)
+			// The error budget is per commit - a new commit starts with a fresh count
+			if p.Commit != failedCommit {
+				failedCommit = p.Commit
+				failures = 0
+			}
+			failures++
+			if p.MaxFailures > 0 && failures >= p.MaxFailures {
+				p.failRollout()
+				haltedCommit = p.Commit
+			}
+		} else {
+			failures = 0
+			p.syncStatus(p.Commit)
+			p.notifyPR(nil)
 		}
 		time.Sleep(30 * time.Second)
 	}
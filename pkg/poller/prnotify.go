@@ -0,0 +1,48 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poller
+
+import (
+	"fmt"
+	"log"
+)
+
+// notifyPR posts a short outcome comment on the originating PR with a permalink to the
+// rollout status comment, so the author of the change sees whether their merge actually
+// made it to the cluster without following the tracking issue
+func (p *Poller) notifyPR(applyErr error) {
+	if !p.NotifyPR || p.Pr == nil {
+		return
+	}
+
+	outcome := "succeeded"
+	if applyErr != nil {
+		outcome = fmt.Sprintf("failed: %v", applyErr)
+	}
+
+	link := ""
+	if comment, err := p.GitClient.FindComment(p.Name, p.User, p.IssueNum); err != nil {
+		log.Printf("could not find rollout comment: %v", err)
+	} else if comment != nil {
+		link = fmt.Sprintf(" - [details](%s)", comment.GetHTMLURL())
+	}
+
+	body := fmt.Sprintf("Rollout of %s by applier %s %s%s", p.Commit, p.Name, outcome, link)
+	if err := p.GitClient.Comment(p.Pr.GetNumber(), body); err != nil {
+		log.Printf("could not comment on PR %d: %v", p.Pr.GetNumber(), err)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	applyv1beta1 "github.com/pwittrock/continuous-apply/pkg/apis/apply/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// queueComment is the managed comment name for the deploy queue
+const queueComment = "deploy-queue"
+
+// publishQueue mirrors the merges waiting behind the applied commit, newest first, into
+// the ContinuousApply status and an optional pinned deploy-queue issue so developers can
+// see where their change is without asking an admin
+func (p *Poller) publishQueue(queue []applyv1beta1.QueuedRollout) {
+	if reflect.DeepEqual(queue, p.lastQueue) {
+		return
+	}
+	p.lastQueue = queue
+	p.setQueue(queue)
+	p.commentQueue(queue)
+}
+
+// setQueue records the queue on the ContinuousApply status
+func (p *Poller) setQueue(queue []applyv1beta1.QueuedRollout) {
+	if p.ProgressNamespace == "" {
+		return
+	}
+	if err := p.statusClient(); err != nil {
+		log.Printf("could not build status client: %v", err)
+		return
+	}
+
+	instance := &applyv1beta1.ContinuousApply{}
+	name := types.NamespacedName{Namespace: p.ProgressNamespace, Name: p.Name}
+	if err := p.crClient.Get(context.TODO(), name, instance); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Printf("could not get %s for status: %v", name, err)
+		}
+		return
+	}
+
+	instance.Status.Queue = queue
+	if err := p.crClient.Update(context.TODO(), instance); err != nil {
+		log.Printf("could not update %s status: %v", name, err)
+	}
+}
+
+// commentQueue mirrors the queue onto the pinned deploy-queue issue
+func (p *Poller) commentQueue(queue []applyv1beta1.QueuedRollout) {
+	if p.QueueIssue == 0 {
+		return
+	}
+
+	buff := &bytes.Buffer{}
+	fmt.Fprintf(buff, "## Deploy queue for %s\n\nUpdated %s.\n\n", p.Name, time.Now().Format(time.RFC1123))
+	if len(queue) == 0 {
+		fmt.Fprintf(buff, "The queue is empty - all matching merges are applied.\n")
+	}
+	for _, q := range queue {
+		fmt.Fprintf(buff, "- #%d (`%s`)", q.PullRequest, q.Commit)
+		if q.Note != "" {
+			fmt.Fprintf(buff, " - %s", q.Note)
+		}
+		fmt.Fprintf(buff, "\n")
+	}
+
+	comment, err := p.GitClient.GetComment(queueComment, p.User, p.QueueIssue)
+	if err != nil || comment == nil {
+		log.Printf("could not get queue comment: %v", err)
+		return
+	}
+	body := buff.String()
+	comment.Body = &body
+	if _, err := p.GitClient.UpdateComment(comment, queueComment, p.User, p.QueueIssue); err != nil {
+		log.Printf("could not update queue comment: %v", err)
+	}
+}
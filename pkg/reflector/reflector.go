@@ -0,0 +1,217 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reflector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pwittrock/continuous-apply/pkg/apis/apply/v1beta1"
+	"github.com/pwittrock/continuous-apply/pkg/git"
+)
+
+// Spec configures the images the Reflector watches and how update PRs are opened
+type Spec struct {
+	// Repo is the Git repo to open image update PRs against.
+	Repo v1beta1.Repo `json:"repo"`
+
+	// Images are the container images to watch for new tags.
+	Images []Image `json:"images"`
+
+	// AddLabels are labels added to update PRs so the poller rolls them out once merged.
+	AddLabels []string `json:"addLabels"`
+}
+
+// Image defines a container image to watch for new tags
+type Image struct {
+	// Name is the image to watch - e.g. gcr.io/project/app
+	Name string `json:"name"`
+
+	// TagPattern is a regular expression new tags must match.
+	TagPattern string `json:"tagPattern"`
+
+	// Path is the kustomize directory whose image override is updated.
+	Path string `json:"path"`
+}
+
+// Reflector watches container registries for new tags matching each Image's TagPattern and
+// opens PRs updating the kustomize image overrides, closing the loop from image build to rollout
+type Reflector struct {
+	Spec
+	AccessToken string
+
+	gitClient *git.GitManager
+}
+
+func (r *Reflector) Run() error {
+	var err error
+	if r.gitClient, err = git.NewManager(r.Repo.Owner, r.Repo.Repo, ""); err != nil {
+		return err
+	}
+	if err := r.gitClient.Clone(); err != nil {
+		return err
+	}
+
+	for {
+		for i := range r.Images {
+			if err := r.sync(&r.Images[i]); err != nil {
+				log.Printf("%v", err)
+			}
+		}
+		time.Sleep(5 * time.Minute)
+	}
+}
+
+// sync checks the registry for a newer matching tag and opens an update PR if one is found
+func (r *Reflector) sync(img *Image) error {
+	pattern, err := regexp.Compile(img.TagPattern)
+	if err != nil {
+		return fmt.Errorf("invalid tagPattern for %s: %v", img.Name, err)
+	}
+
+	tags, err := listTags(img.Name)
+	if err != nil {
+		return fmt.Errorf("could not list tags for %s: %v", img.Name, err)
+	}
+
+	var matching []string
+	for _, t := range tags {
+		if pattern.MatchString(t) {
+			matching = append(matching, t)
+		}
+	}
+	if len(matching) == 0 {
+		return nil
+	}
+	sort.Slice(matching, func(i, j int) bool { return tagLess(matching[i], matching[j]) })
+	latest := matching[len(matching)-1]
+
+	return r.proposeUpdate(img, latest)
+}
+
+// proposeUpdate opens a PR setting the kustomize image override for img to tag, unless one has
+// already been opened
+func (r *Reflector) proposeUpdate(img *Image, tag string) error {
+	branch := fmt.Sprintf("image-update-%s-%s", strings.Replace(img.Name, "/", "-", -1), tag)
+
+	// Check whether the update has already been proposed
+	prs, _, err := r.gitClient.PullRequests.List(context.TODO(), r.Repo.Owner, r.Repo.Repo,
+		&github.PullRequestListOptions{Head: fmt.Sprintf("%s:%s", r.Repo.Owner, branch), State: "all"})
+	if err != nil {
+		return err
+	}
+	if len(prs) > 0 {
+		return nil
+	}
+
+	log.Printf("proposing %s=%s\n", img.Name, tag)
+
+	// Create the update commit on a new branch
+	for _, args := range [][]string{
+		{"git", "checkout", "-B", branch, "origin/master"},
+		{"kustomize", "edit", "set", "image", fmt.Sprintf("%s=%s:%s", img.Name, img.Name, tag)},
+		{"git", "commit", "-a", "-m", fmt.Sprintf("Update %s to %s", img.Name, tag)},
+		{"git", "push", "origin", branch},
+		{"git", "checkout", "master"},
+	} {
+		cmd := exec.Command(args[0], args[1:]...)
+		if args[0] == "kustomize" {
+			cmd.Dir = img.Path
+		}
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		log.Printf(strings.Join(cmd.Args, " "))
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("could not commit image update %v", err)
+		}
+	}
+
+	// Open the PR and label it for the poller
+	title := fmt.Sprintf("Update %s to %s", img.Name, tag)
+	body := fmt.Sprintf("Automated image update for `%s` in `%s`.", img.Name, img.Path)
+	base := "master"
+	pr, _, err := r.gitClient.PullRequests.Create(context.TODO(), r.Repo.Owner, r.Repo.Repo,
+		&github.NewPullRequest{Title: &title, Body: &body, Head: &branch, Base: &base})
+	if err != nil {
+		return err
+	}
+	if len(r.AddLabels) > 0 {
+		if err := r.gitClient.AddLabels(pr.GetNumber(), r.AddLabels...); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("opened PR %d for %s=%s\n", pr.GetNumber(), img.Name, tag)
+	return nil
+}
+
+// listTags fetches the tags for an image from its registry using the Docker Registry v2 API
+func listTags(image string) ([]string, error) {
+	registry, repo := "registry-1.docker.io", image
+	if parts := strings.SplitN(image, "/", 2); len(parts) == 2 && strings.ContainsAny(parts[0], ".:") {
+		registry, repo = parts[0], parts[1]
+	}
+
+	resp, err := http.Get(fmt.Sprintf("https://%s/v2/%s/tags/list", registry, repo))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %s for %s", resp.Status, image)
+	}
+
+	tags := &struct {
+		Tags []string `json:"tags"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(tags); err != nil {
+		return nil, err
+	}
+	return tags.Tags, nil
+}
+
+// tagLess orders tags by their numeric segments so semver tags sort correctly, falling back to
+// a string comparison for non-numeric segments
+func tagLess(a, b string) bool {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		ai, aerr := strconv.Atoi(as[i])
+		bi, berr := strconv.Atoi(bs[i])
+		if aerr != nil || berr != nil {
+			if as[i] != bs[i] {
+				return as[i] < bs[i]
+			}
+			continue
+		}
+		if ai != bi {
+			return ai < bi
+		}
+	}
+	return len(as) < len(bs)
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reflector
+
+import "testing"
+
+func TestTagLess(t *testing.T) {
+	cases := []struct {
+		a, b string
+		less bool
+	}{
+		{"v1.2.3", "v1.10.0", true},
+		{"v1.10.0", "v1.2.3", false},
+		{"1.2", "1.2.1", true},
+		{"v2.0.0", "v10.0.0", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"alpha", "beta", true},
+		{"v1.2.3-alpha", "v1.2.3-beta", true},
+	}
+	for _, c := range cases {
+		if got := tagLess(c.a, c.b); got != c.less {
+			t.Errorf("tagLess(%q, %q) = %v, expected %v", c.a, c.b, got, c.less)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Requests sums the cpu and memory requests the object's containers would add to the
+// cluster, multiplied by the object's replica count
+func (o *Object) Requests() (cpu, memory resource.Quantity) {
+	replicas := int64(1)
+	if r, found, _ := unstructured.NestedInt64(o.parsed.Object, "spec", "replicas"); found {
+		replicas = r
+	}
+	sumRequests(o.parsed.Object, replicas, &cpu, &memory)
+	return cpu, memory
+}
+
+// sumRequests recursively walks parsed config, adding up container cpu and memory requests
+func sumRequests(v interface{}, replicas int64, cpu, memory *resource.Quantity) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if cs, ok := t["containers"].([]interface{}); ok {
+			for _, c := range cs {
+				m, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				resources, _ := m["resources"].(map[string]interface{})
+				requests, _ := resources["requests"].(map[string]interface{})
+				if s, ok := requests["cpu"].(string); ok {
+					if q, err := resource.ParseQuantity(s); err == nil {
+						cpu.Add(*resource.NewMilliQuantity(q.MilliValue()*replicas, q.Format))
+					}
+				}
+				if s, ok := requests["memory"].(string); ok {
+					if q, err := resource.ParseQuantity(s); err == nil {
+						memory.Add(*resource.NewQuantity(q.Value()*replicas, q.Format))
+					}
+				}
+			}
+			return
+		}
+		for _, val := range t {
+			sumRequests(val, replicas, cpu, memory)
+		}
+	case []interface{}:
+		for _, val := range t {
+			sumRequests(val, replicas, cpu, memory)
+		}
+	}
+}
@@ -19,22 +19,259 @@ package rollout
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
+const (
+	// HookAnnotation marks an object as a hook to be run at a rollout phase instead of applied normally
+	HookAnnotation = "apply.k8s.io/hook"
+
+	// HookDeletePolicyAnnotation controls when a hook object is deleted after running
+	HookDeletePolicyAnnotation = "apply.k8s.io/hook-delete-policy"
+
+	// HookPreSync hooks are run before the Rollout Objects are applied
+	HookPreSync = "PreSync"
+
+	// HookPostSync hooks are run after the Rollout Objects have rolled out successfully
+	HookPostSync = "PostSync"
+
+	// HookSyncFail hooks are run if applying the Rollout Objects fails
+	HookSyncFail = "SyncFail"
+
+	// HookDeleteSucceeded deletes the hook object after it completes successfully
+	HookDeleteSucceeded = "HookSucceeded"
+
+	// HookDeleteFailed deletes the hook object after it fails
+	HookDeleteFailed = "HookFailed"
+
+	// HookDeleteBeforeCreation deletes any hook object left over from a previous rollout before re-creating it
+	HookDeleteBeforeCreation = "BeforeHookCreation"
+
+	// WaveAnnotation assigns an object to a wave.  Waves are applied in ascending order, waiting
+	// for each wave to rollout before applying the next.
+	WaveAnnotation = "apply.k8s.io/wave"
+
+	// PartOfLabel names the application an object belongs to, used to group objects in the
+	// rollout comment
+	PartOfLabel = "app.kubernetes.io/part-of"
+
+	// ReadinessQueryAnnotation is a PromQL expression that must evaluate true before the
+	// object is considered Done, letting teams define app-level readiness beyond
+	// Kubernetes status - e.g. an error-rate or saturation query
+	ReadinessQueryAnnotation = "apply.k8s.io/readiness-query"
+
+	// ReadinessForAnnotation is how long the readiness query must hold true before the
+	// object is considered Done, e.g. 30s.  Unset accepts the first true evaluation.
+	ReadinessForAnnotation = "apply.k8s.io/readiness-for"
+
+	// ColorLabel is the blue/green color of a Deployment's pods in blue-green targets.
+	// Service selectors are pointed at the live color's pods.
+	ColorLabel = "apply.k8s.io/color"
+
+	// AppliedByLabel is stamped on every applied object with the name of the Applier
+	AppliedByLabel = "apply.k8s.io/applier"
+
+	// AppliedCommitLabel is stamped on every applied object with the commit SHA it was
+	// rendered from, enabling drift queries like
+	// kubectl get all -l apply.k8s.io/commit!=<sha>
+	AppliedCommitLabel = "apply.k8s.io/commit"
+
+	// AppliedIssueAnnotation is stamped on every applied object with the issue or PR number
+	// tracking the rollout
+	AppliedIssueAnnotation = "apply.k8s.io/issue"
+
+	// AppliedPathAnnotation is stamped on every applied object with the target path it was
+	// rendered from
+	AppliedPathAnnotation = "apply.k8s.io/target-path"
+)
+
 // Rollout contains one or more Objects to rollout
 type Rollout struct {
 	Icon    string
 	Status  string
 	Path    string
 	Objects []*Object
+
+	// Hooks are Objects annotated with HookAnnotation.  They are run at the phase given
+	// by their annotation instead of being applied with the other Objects.
+	Hooks []*Object
+
+	// Started is when the rollout of the target began
+	Started time.Time
+
+	// Finished is when the rollout of the target completed
+	Finished time.Time
+
+	// ExpectedDuration is how long past rollouts of the target have taken, used to estimate
+	// the time remaining.  Zero disables the estimate.
+	ExpectedDuration time.Duration
+
+	// MedianDuration is the trailing median of the target's past rollout durations, used to
+	// flag rollouts that are running significantly slower than usual.  Zero disables the check.
+	MedianDuration time.Duration
+
+	// CreatedNamespaces are namespaces that were created on demand for the target's objects
+	CreatedNamespaces []string
+
+	// Findings are security findings from scanning the target's rendered objects
+	Findings []string
+
+	// Warnings are non-fatal pre-apply check findings surfaced in the comment
+	Warnings []string
+
+	// SmokeTest is the outcome of the target's post-rollout smoke test suite
+	SmokeTest string
+
+	// BlueGreen tracks which color is live and which was retired for blue-green targets
+	BlueGreen string
+}
+
+// ObjectGroup is the set of a target's objects belonging to one application
+type ObjectGroup struct {
+	// Name is the application the objects belong to.  Empty for unlabeled objects.
+	Name    string
+	Objects []*Object
+}
+
+// Progress renders the fraction of the group's objects that have completed
+func (g *ObjectGroup) Progress() string {
+	return progress(g.Objects)
+}
+
+// maxDetailedObjects is the object count above which a target's comment section is
+// summarized instead of listing every object, keeping very large targets under GitHub's
+// comment size limit
+const maxDetailedObjects = 200
+
+// Summarized returns true if the target has too many objects to list individually in the
+// comment.  Summarized targets render counts per state with only the objects needing
+// attention expanded; the published summary artifact carries the full detail.
+func (r *Rollout) Summarized() bool {
+	return len(r.Objects) > maxDetailedObjects
+}
+
+// StatusCounts tallies the target's objects by state for the summarized view
+func (r *Rollout) StatusCounts() string {
+	done, started, pending := 0, 0, 0
+	for _, o := range r.Objects {
+		switch {
+		case o.Done:
+			done++
+		case !o.Started.IsZero():
+			started++
+		default:
+			pending++
+		}
+	}
+	return fmt.Sprintf("%d of %d objects done, %d in progress, %d pending",
+		done, len(r.Objects), started, pending)
+}
+
+// attention returns the objects worth expanding in the summarized view - refused objects
+// and objects that started but have not completed - so failures stay visible when the
+// listing is collapsed
+func (r *Rollout) attention() []*Object {
+	var attention []*Object
+	for _, o := range r.Objects {
+		if o.Done {
+			continue
+		}
+		if strings.HasPrefix(o.ApplyStatus, "refused") || !o.Started.IsZero() {
+			attention = append(attention, o)
+		}
+	}
+	if len(attention) > maxDetailedObjects {
+		attention = attention[:maxDetailedObjects]
+	}
+	return attention
+}
+
+// Groups partitions the target's objects by owning application so large rollouts are
+// scannable.  Applications are ordered by name with unlabeled objects last.
+func (r *Rollout) Groups() []*ObjectGroup {
+	// Very large targets list only the objects needing attention
+	if r.Summarized() {
+		attention := r.attention()
+		if len(attention) == 0 {
+			return []*ObjectGroup{}
+		}
+		return []*ObjectGroup{{Name: "needs attention", Objects: attention}}
+	}
+
+	byName := map[string]*ObjectGroup{}
+	names := []string{}
+	for _, o := range r.Objects {
+		g, found := byName[o.PartOf]
+		if !found {
+			g = &ObjectGroup{Name: o.PartOf}
+			byName[o.PartOf] = g
+			names = append(names, o.PartOf)
+		}
+		g.Objects = append(g.Objects, o)
+	}
+	sort.Strings(names)
+
+	groups := []*ObjectGroup{}
+	for _, n := range names {
+		if n == "" {
+			continue
+		}
+		groups = append(groups, byName[n])
+	}
+	// Unlabeled objects come last
+	if g, found := byName[""]; found {
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// Progress renders the fraction of the target's objects that have completed
+func (r *Rollout) Progress() string {
+	var objects []*Object
+	objects = append(objects, r.Objects...)
+	objects = append(objects, r.Hooks...)
+	return progress(objects)
+}
+
+// Regression warns when the target's rollout has taken significantly longer than the trailing
+// median of its past rollouts, which often indicates cluster capacity or image pull problems
+func (r *Rollout) Regression() string {
+	if r.MedianDuration == 0 || r.Started.IsZero() {
+		return ""
+	}
+	end := r.Finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	elapsed := end.Sub(r.Started)
+	if elapsed <= 2*r.MedianDuration {
+		return ""
+	}
+	return fmt.Sprintf("rollout took %s, significantly slower than the trailing median of %s",
+		elapsed.Round(time.Second), r.MedianDuration.Round(time.Second))
+}
+
+// ETA estimates the time remaining for the target based on the durations of its past rollouts
+func (r *Rollout) ETA() string {
+	if r.ExpectedDuration == 0 || r.Started.IsZero() || !r.Finished.IsZero() {
+		return ""
+	}
+	left := r.ExpectedDuration - time.Since(r.Started)
+	if left <= 0 {
+		return "any moment"
+	}
+	return "~" + left.Round(time.Second).String()
 }
 
 type Rollouts struct {
@@ -42,6 +279,45 @@ type Rollouts struct {
 	Status   string
 	Icon     string
 	Rollouts []*Rollout
+
+	// SummaryURL links to the published machine-readable summary of the rollout
+	SummaryURL string
+}
+
+// Progress renders a progress bar for the fraction of objects that have completed across
+// all targets
+func (r *Rollouts) Progress() string {
+	var objects []*Object
+	for _, ro := range r.Rollouts {
+		objects = append(objects, ro.Objects...)
+		objects = append(objects, ro.Hooks...)
+	}
+	if len(objects) == 0 {
+		return ""
+	}
+	done := 0
+	for _, o := range objects {
+		if o.Done {
+			done++
+		}
+	}
+	filled := done * 10 / len(objects)
+	return fmt.Sprintf("`%s%s` %s", strings.Repeat("█", filled), strings.Repeat("░", 10-filled),
+		progress(objects))
+}
+
+// progress renders the fraction of objects that have completed as a count and percentage
+func progress(objects []*Object) string {
+	if len(objects) == 0 {
+		return ""
+	}
+	done := 0
+	for _, o := range objects {
+		if o.Done {
+			done++
+		}
+	}
+	return fmt.Sprintf("%d/%d objects - %d%%", done, len(objects), done*100/len(objects))
 }
 
 // Object encapsulates the metadata and state for an object
@@ -57,6 +333,331 @@ type Object struct {
 	RolloutStatus        string
 	RolloutStatusHistory []string
 	Done                 bool
+
+	// Hook is the rollout phase to run the object at - one of PreSync, PostSync, SyncFail.
+	// Empty for objects that are not hooks.
+	Hook string
+
+	// HookDeletePolicy controls when the hook object is deleted
+	HookDeletePolicy string
+
+	// Wave is the wave the object is applied in.  Objects without the wave annotation are in wave 0.
+	Wave int
+
+	// ReadinessQuery is the PromQL expression from the readiness-query annotation that
+	// must evaluate true before the object is considered Done.  Empty disables the gate.
+	ReadinessQuery string
+
+	// ReadinessFor is how long the readiness query must hold true, from the
+	// readiness-for annotation
+	ReadinessFor time.Duration
+
+	// ReadySince is when the readiness query most recently started evaluating true.
+	// Zero when the query is false or has not been evaluated.
+	ReadySince time.Time
+
+	// PartOf is the application the object belongs to, from the app.kubernetes.io/part-of
+	// label.  Empty for objects that are not labeled.
+	PartOf string
+
+	// SharedWith is the path of the target that applies this object when the same object is
+	// rendered by multiple targets - e.g. a shared base.  Empty for objects this target
+	// applies itself.
+	SharedWith string
+
+	// Primary is the identical object rendered by an earlier target, which applies and
+	// polls on behalf of this one
+	Primary *Object
+
+	// Revision is the revision of the rollout this apply triggered, captured right after
+	// the apply and passed to the StatusViewer so a concurrent manual rollout can not
+	// satisfy the wait.  Zero skips the revision check.
+	Revision int64
+
+	// URL optionally links the object's name in the status comment to a dashboard showing
+	// its live state
+	URL string
+
+	// Started is when the object was applied
+	Started time.Time
+
+	// Finished is when the object's rollout completed
+	Finished time.Time
+}
+
+// Labels returns the object's labels from the rendered config
+func (o *Object) Labels() map[string]string {
+	return o.parsed.GetLabels()
+}
+
+// IsHook returns true if the object is annotated as a hook
+func (o *Object) IsHook() bool {
+	return o.Hook != ""
+}
+
+// State is the object's position in the rollout state machine - Pending, Applied, Waiting,
+// Done, or Failed - derived from the apply and rollout bookkeeping
+func (o *Object) State() string {
+	switch {
+	case strings.HasPrefix(o.ApplyStatus, "refused") || strings.HasPrefix(o.RolloutStatus, "error"):
+		return "Failed"
+	case o.Done:
+		return "Done"
+	case o.RolloutStatus != "":
+		return "Waiting"
+	case !o.Started.IsZero():
+		return "Applied"
+	default:
+		return "Pending"
+	}
+}
+
+// Duration is how long the object took from apply to rollout completion.  Empty until the
+// object is done.
+func (o *Object) Duration() string {
+	if o.Started.IsZero() || o.Finished.IsZero() {
+		return ""
+	}
+	return o.Finished.Sub(o.Started).Round(time.Second).String()
+}
+
+// Unchanged returns true if the apply reported the object unchanged, so the rollout was a
+// no-op for it
+func (o *Object) Unchanged() bool {
+	return strings.HasSuffix(o.ApplyStatus, " unchanged") || o.ApplyStatus == "unchanged (skipped)"
+}
+
+// HasPlaintextSecretData returns true if the object is a Secret with inline data or stringData
+func (o *Object) HasPlaintextSecretData() bool {
+	if o.Kind != "Secret" || o.Group != "" {
+		return false
+	}
+	if d, ok := o.parsed.Object["data"].(map[string]interface{}); ok && len(d) > 0 {
+		return true
+	}
+	if d, ok := o.parsed.Object["stringData"].(map[string]interface{}); ok && len(d) > 0 {
+		return true
+	}
+	return false
+}
+
+// clusterScopedKinds are kinds known to be cluster-scoped, which namespace overrides do not apply to
+var clusterScopedKinds = sets.NewString("Namespace", "Node", "PersistentVolume", "ClusterRole",
+	"ClusterRoleBinding", "CustomResourceDefinition", "StorageClass", "PriorityClass", "APIService")
+
+// IsClusterScoped returns true if the kind is known to be cluster-scoped
+func IsClusterScoped(kind string) bool {
+	return clusterScopedKinds.Has(kind)
+}
+
+// SetNamespace overrides the object's namespace, updating the serialized config that is applied
+// to the cluster
+func (o *Object) SetNamespace(ns string) error {
+	o.parsed.SetNamespace(ns)
+	o.Namespace = ns
+	return o.updateSerialized()
+}
+
+// SetColor stamps the blue/green color onto the object for blue-green targets, updating the
+// serialized config that is applied to the cluster.  Deployments are renamed per color so
+// both versions run side by side; Service selectors are pointed at the color's pods.  Other
+// kinds are left unchanged.
+func (o *Object) SetColor(color string) error {
+	switch o.Kind {
+	case "Service":
+		sel, _, err := unstructured.NestedStringMap(o.parsed.Object, "spec", "selector")
+		if err != nil {
+			return err
+		}
+		if sel == nil {
+			sel = map[string]string{}
+		}
+		sel[ColorLabel] = color
+		if err := unstructured.SetNestedStringMap(o.parsed.Object, sel, "spec", "selector"); err != nil {
+			return err
+		}
+	case "Deployment":
+		o.Name = o.Name + "-" + color
+		o.parsed.SetName(o.Name)
+		l := o.parsed.GetLabels()
+		if l == nil {
+			l = map[string]string{}
+		}
+		l[ColorLabel] = color
+		o.parsed.SetLabels(l)
+		for _, path := range [][]string{
+			{"spec", "selector", "matchLabels"},
+			{"spec", "template", "metadata", "labels"},
+		} {
+			m, _, err := unstructured.NestedStringMap(o.parsed.Object, path...)
+			if err != nil {
+				return err
+			}
+			if m == nil {
+				m = map[string]string{}
+			}
+			m[ColorLabel] = color
+			if err := unstructured.SetNestedStringMap(o.parsed.Object, m, path...); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	return o.updateSerialized()
+}
+
+// Stamp merges tracking labels and annotations into the object, updating the serialized
+// config that is applied to the cluster
+func (o *Object) Stamp(labels, annotations map[string]string) error {
+	l := o.parsed.GetLabels()
+	if l == nil {
+		l = map[string]string{}
+	}
+	for k, v := range labels {
+		l[k] = v
+	}
+	o.parsed.SetLabels(l)
+
+	a := o.parsed.GetAnnotations()
+	if a == nil {
+		a = map[string]string{}
+	}
+	for k, v := range annotations {
+		a[k] = v
+	}
+	o.parsed.SetAnnotations(a)
+	return o.updateSerialized()
+}
+
+// PinImages replaces the tag or digest of container images in the object whose name matches a
+// pinned image, updating the serialized config that is applied to the cluster
+func (o *Object) PinImages(pins map[string]string) error {
+	if len(pins) == 0 {
+		return nil
+	}
+	pinImages(o.parsed.Object, pins)
+	return o.updateSerialized()
+}
+
+// updateSerialized re-serializes the parsed config after it has been modified, keeping the typed
+// object in sync with the unstructured object
+func (o *Object) updateSerialized() error {
+	j, err := json.Marshal(o.parsed.Object)
+	if err != nil {
+		return fmt.Errorf("could not marshal json %v", err)
+	}
+	o.JSON = j
+	o.Raw = j
+
+	if _, ok := o.Object.(*unstructured.Unstructured); !ok {
+		if err := json.Unmarshal(o.JSON, o.Object); err != nil {
+			return fmt.Errorf("could not unmarshal json %v\n%s", err, o.Raw)
+		}
+	}
+	return nil
+}
+
+// pinImages recursively walks parsed config, replacing image fields whose name matches a pin
+func pinImages(v interface{}, pins map[string]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if k == "image" {
+				if s, ok := val.(string); ok {
+					t[k] = pinImage(s, pins)
+					continue
+				}
+			}
+			pinImages(val, pins)
+		}
+	case []interface{}:
+		for _, e := range t {
+			pinImages(e, pins)
+		}
+	}
+}
+
+// pinImage returns the pinned version of the image if its name is pinned
+func pinImage(image string, pins map[string]string) string {
+	pin, found := pins[imageName(image)]
+	if !found {
+		return image
+	}
+	if strings.HasPrefix(pin, "sha256:") {
+		return imageName(image) + "@" + pin
+	}
+	return imageName(image) + ":" + pin
+}
+
+// imageName strips the tag or digest from an image, handling registries with ports
+func imageName(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i]
+	}
+	slash := strings.LastIndex(image, "/")
+	if colon := strings.LastIndex(image, ":"); colon > slash {
+		return image[:colon]
+	}
+	return image
+}
+
+// Waves groups objects by their wave, returning the groups in ascending wave order.
+func Waves(objects []*Object) [][]*Object {
+	grouped := map[int][]*Object{}
+	for _, o := range objects {
+		grouped[o.Wave] = append(grouped[o.Wave], o)
+	}
+
+	var order []int
+	for w := range grouped {
+		order = append(order, w)
+	}
+	sort.Ints(order)
+
+	var waves [][]*Object
+	for _, w := range order {
+		waves = append(waves, grouped[w])
+	}
+	return waves
+}
+
+// IsSelf returns true if the object is part of the continuous-apply system itself - the
+// apply.k8s.io CRDs and CRs, or a workload labeled as an applier Deployment.  Must be
+// called before the tracking labels are stamped, which add the applier label to every
+// object.
+func (o *Object) IsSelf() bool {
+	if o.Group == "apply.k8s.io" {
+		return true
+	}
+	if o.Kind == "CustomResourceDefinition" && strings.HasSuffix(o.Name, ".apply.k8s.io") {
+		return true
+	}
+	_, found := o.parsed.GetLabels()[AppliedByLabel]
+	return found
+}
+
+// DeferSelf moves objects that are part of the continuous-apply system itself into a wave
+// after every other object, so a self-managing repo updates its own controller last
+// instead of killing the applier mid-rollout.  Returns true if any objects were deferred.
+func DeferSelf(objects []*Object) bool {
+	last := 0
+	var selves []*Object
+	for _, o := range objects {
+		if o.IsSelf() {
+			selves = append(selves, o)
+			continue
+		}
+		if o.Wave > last {
+			last = o.Wave
+		}
+	}
+	for _, o := range selves {
+		if o.Wave <= last {
+			o.Wave = last + 1
+		}
+	}
+	return len(selves) > 0
 }
 
 // Display returns the display name of a object
@@ -104,6 +705,28 @@ func ParseObject(o []byte) (*Object, error) {
 		return nil, fmt.Errorf("apiVersion not recognized %v", obj.parsed.Object["apiVersion"])
 	}
 
+	// Set the hook phase and delete policy if the object is annotated as a hook
+	obj.Hook = obj.parsed.GetAnnotations()[HookAnnotation]
+	obj.HookDeletePolicy = obj.parsed.GetAnnotations()[HookDeletePolicyAnnotation]
+
+	// Set the owning application if the object is labeled with one
+	obj.PartOf = obj.parsed.GetLabels()[PartOfLabel]
+
+	// Set the wave if the object is annotated with one
+	if w, found := obj.parsed.GetAnnotations()[WaveAnnotation]; found {
+		if obj.Wave, err = strconv.Atoi(w); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation %q: %v", WaveAnnotation, w, err)
+		}
+	}
+
+	// Set the app-level readiness gate if the object is annotated with a query
+	obj.ReadinessQuery = obj.parsed.GetAnnotations()[ReadinessQueryAnnotation]
+	if d, found := obj.parsed.GetAnnotations()[ReadinessForAnnotation]; found {
+		if obj.ReadinessFor, err = time.ParseDuration(d); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation %q: %v", ReadinessForAnnotation, d, err)
+		}
+	}
+
 	if obj.Object, err = scheme.Scheme.New(obj.GroupVersionKind); err != nil {
 		// Object type not registered with the scheme.  May be +versioned skewed or an extension.
 		// Use the unstructured object as the runtime.Object
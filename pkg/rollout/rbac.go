@@ -0,0 +1,167 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// applyVerbs are the verbs the applier needs to create, update and watch the objects it manages
+var applyVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// irregularResources maps kinds whose resource name is not formed by the usual pluralization rules
+var irregularResources = map[string]string{
+	"Endpoints": "endpoints",
+}
+
+// GenerateRBAC emits a minimal Role/ClusterRole and binding granting serviceAccount (in
+// serviceAccountNamespace) just enough access to apply objects.  Namespaced objects produce a
+// Role and RoleBinding per namespace, cluster-scoped objects a ClusterRole and
+// ClusterRoleBinding.  Generated names are prefixed with name.
+func GenerateRBAC(name, serviceAccount, serviceAccountNamespace string, objects []*Object) (string, error) {
+	subject := rbacv1.Subject{
+		Kind:      "ServiceAccount",
+		Name:      serviceAccount,
+		Namespace: serviceAccountNamespace,
+	}
+
+	clusterResources := sets.NewString()
+	namespaceResources := map[string]sets.String{}
+	for _, o := range objects {
+		key := fmt.Sprintf("%s/%s", o.Group, ResourceFor(o.Kind))
+		if IsClusterScoped(o.Kind) {
+			clusterResources.Insert(key)
+			continue
+		}
+		ns := o.Namespace
+		if ns == "" {
+			ns = "default"
+		}
+		if namespaceResources[ns] == nil {
+			namespaceResources[ns] = sets.NewString()
+		}
+		namespaceResources[ns].Insert(key)
+	}
+
+	var rendered []string
+	if clusterResources.Len() > 0 {
+		role := &rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Rules:      rulesFor(clusterResources),
+		}
+		binding := &rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Subjects:   []rbacv1.Subject{subject},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: name,
+			},
+		}
+		if err := render(&rendered, role, binding); err != nil {
+			return "", err
+		}
+	}
+
+	namespaces := []string{}
+	for ns := range namespaceResources {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		role := &rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Rules:      rulesFor(namespaceResources[ns]),
+		}
+		binding := &rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Subjects:   []rbacv1.Subject{subject},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: name,
+			},
+		}
+		if err := render(&rendered, role, binding); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(rendered, "---\n"), nil
+}
+
+// rulesFor converts a set of group/resource keys into PolicyRules, one rule per API group
+func rulesFor(resources sets.String) []rbacv1.PolicyRule {
+	byGroup := map[string]sets.String{}
+	for _, key := range resources.List() {
+		parts := strings.SplitN(key, "/", 2)
+		if byGroup[parts[0]] == nil {
+			byGroup[parts[0]] = sets.NewString()
+		}
+		byGroup[parts[0]].Insert(parts[1])
+	}
+
+	groups := []string{}
+	for g := range byGroup {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	rules := []rbacv1.PolicyRule{}
+	for _, g := range groups {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{g},
+			Resources: byGroup[g].List(),
+			Verbs:     applyVerbs,
+		})
+	}
+	return rules
+}
+
+// ResourceFor derives the resource name the API must be addressed with from the object kind
+func ResourceFor(kind string) string {
+	if r, found := irregularResources[kind]; found {
+		return r
+	}
+	lower := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(lower, "s"):
+		return lower + "es"
+	case strings.HasSuffix(lower, "y"):
+		return strings.TrimSuffix(lower, "y") + "ies"
+	default:
+		return lower + "s"
+	}
+}
+
+func render(rendered *[]string, objects ...interface{}) error {
+	for _, o := range objects {
+		b, err := yaml.Marshal(o)
+		if err != nil {
+			return err
+		}
+		*rendered = append(*rendered, string(b))
+	}
+	return nil
+}
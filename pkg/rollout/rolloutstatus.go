@@ -20,11 +20,20 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	appsv1beta1 "k8s.io/api/apps/v1beta1"
 	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	batchv2alpha1 "k8s.io/api/batch/v2alpha1"
+	corev1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -66,8 +75,42 @@ func GetStatusViewer(o runtime.Object, c client.Client) StatusViewer {
 	case *appsv1.DaemonSet:
 		return &DaemonSetStatusViewer{Client: c}
 
+	// Ingress cases
+	case *extensionsv1beta1.Ingress:
+		return &IngressStatusViewer{Client: c}
+
+	// HorizontalPodAutoscaler cases
+	case *autoscalingv1.HorizontalPodAutoscaler:
+		return &HorizontalPodAutoscalerStatusViewer{Client: c}
+	case *autoscalingv2beta1.HorizontalPodAutoscaler:
+		return &HorizontalPodAutoscalerStatusViewer{Client: c}
+
+	// PodDisruptionBudget cases
+	case *policyv1beta1.PodDisruptionBudget:
+		return &PodDisruptionBudgetStatusViewer{Client: c}
+
+	// Job cases
+	case *batchv1.Job:
+		return &JobStatusViewer{Client: c}
+
+	// CronJob cases
+	case *batchv1beta1.CronJob:
+		return &CronJobStatusViewer{Client: c}
+	case *batchv2alpha1.CronJob:
+		return &CronJobStatusViewer{Client: c}
+
+	// Webhook configuration cases - the scheme registers admissionregistration/v1beta1,
+	// so these arrive typed rather than through the unstructured dispatch
+	case *admissionregistrationv1beta1.ValidatingWebhookConfiguration:
+		return &WebhookConfigStatusViewer{Client: c,
+			GVK: admissionregistrationv1beta1.SchemeGroupVersion.WithKind("ValidatingWebhookConfiguration")}
+	case *admissionregistrationv1beta1.MutatingWebhookConfiguration:
+		return &WebhookConfigStatusViewer{Client: c,
+			GVK: admissionregistrationv1beta1.SchemeGroupVersion.WithKind("MutatingWebhookConfiguration")}
+
 	case *unstructured.Unstructured:
-		return nil
+		// Extension types are dispatched on their group and kind
+		return getUnstructuredViewer(o.(*unstructured.Unstructured).GroupVersionKind(), c)
 	default:
 		// no match; here v has the same type as i
 	}
@@ -97,6 +140,131 @@ type StatefulSetStatusViewer struct {
 	client.Client
 }
 
+// IngressStatusViewer implements the StatusViewer interface.
+type IngressStatusViewer struct {
+	client.Client
+}
+
+// HorizontalPodAutoscalerStatusViewer implements the StatusViewer interface.
+type HorizontalPodAutoscalerStatusViewer struct {
+	client.Client
+}
+
+// PodDisruptionBudgetStatusViewer implements the StatusViewer interface.
+type PodDisruptionBudgetStatusViewer struct {
+	client.Client
+}
+
+// JobStatusViewer implements the StatusViewer interface.
+type JobStatusViewer struct {
+	client.Client
+}
+
+// CronJobStatusViewer implements the StatusViewer interface.
+type CronJobStatusViewer struct {
+	client.Client
+}
+
+// Status returns a message describing job status, and a bool value indicating if the status is
+// considered done.  A job is done once its Complete condition is True, and failed once its
+// Failed condition is True.
+func (s *JobStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	job := &batchv1.Job{}
+	err := s.Get(context.TODO(), name, job)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobComplete && c.Status == corev1.ConditionTrue {
+			return fmt.Sprintf("job %q completed\n", name), true, nil
+		}
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return "", false, fmt.Errorf("job %q failed after %d attempts: %s", name, job.Status.Failed, c.Message)
+		}
+	}
+	if job.Spec.BackoffLimit != nil && job.Status.Failed > 0 {
+		return fmt.Sprintf("Waiting for job %q to complete: %d of %d attempts failed, %d pods active...\n",
+			name, job.Status.Failed, *job.Spec.BackoffLimit, job.Status.Active), false, nil
+	}
+	return fmt.Sprintf("Waiting for job %q to complete: %d pods active...\n", name, job.Status.Active), false, nil
+}
+
+// Status returns a message describing cron job status, and a bool value indicating if the status
+// is considered done.  A cron job is done as soon as it has been accepted - it runs on its own
+// schedule rather than as part of the rollout.
+func (s *CronJobStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	cj := &batchv1beta1.CronJob{}
+	err := s.Get(context.TODO(), name, cj)
+	if err != nil {
+		return "", false, err
+	}
+	return fmt.Sprintf("cronjob %q scheduled: %s\n", name, cj.Spec.Schedule), true, nil
+}
+
+// Status returns a message describing autoscaler status, and a bool value indicating if the
+// status is considered done.  An autoscaler is done once the controller has observed the
+// applied spec.
+func (s *HorizontalPodAutoscalerStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	hpa := &autoscalingv1.HorizontalPodAutoscaler{}
+	err := s.Get(context.TODO(), name, hpa)
+	if err != nil {
+		return "", false, err
+	}
+
+	if hpa.Status.ObservedGeneration != nil && hpa.Generation > *hpa.Status.ObservedGeneration {
+		return fmt.Sprintf("Waiting for horizontalpodautoscaler spec update to be observed...\n"), false, nil
+	}
+	return fmt.Sprintf("horizontalpodautoscaler %q scaling %q: %d current / %d desired replicas\n",
+		name, hpa.Spec.ScaleTargetRef.Name, hpa.Status.CurrentReplicas, hpa.Status.DesiredReplicas), true, nil
+}
+
+// Status returns a message describing disruption budget status, and a bool value indicating if
+// the status is considered done.  A disruption budget is done once the controller has observed
+// the applied spec and enough pods are healthy.
+func (s *PodDisruptionBudgetStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	pdb := &policyv1beta1.PodDisruptionBudget{}
+	err := s.Get(context.TODO(), name, pdb)
+	if err != nil {
+		return "", false, err
+	}
+
+	if pdb.Generation > pdb.Status.ObservedGeneration {
+		return fmt.Sprintf("Waiting for poddisruptionbudget spec update to be observed...\n"), false, nil
+	}
+	if pdb.Status.CurrentHealthy < pdb.Status.DesiredHealthy {
+		return fmt.Sprintf("Waiting for poddisruptionbudget %q: %d of %d desired healthy pods...\n",
+			name, pdb.Status.CurrentHealthy, pdb.Status.DesiredHealthy), false, nil
+	}
+	return fmt.Sprintf("poddisruptionbudget %q satisfied: %d healthy pods, %d disruptions allowed\n",
+		name, pdb.Status.CurrentHealthy, pdb.Status.PodDisruptionsAllowed), true, nil
+}
+
+// Status returns a message describing ingress status, and a bool value indicating if the status
+// is considered done.  An ingress is done once the load balancer has assigned it an address,
+// which is reported in the message.
+func (s *IngressStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	ing := &extensionsv1beta1.Ingress{}
+	err := s.Get(context.TODO(), name, ing)
+	if err != nil {
+		return "", false, err
+	}
+
+	var addrs []string
+	for _, i := range ing.Status.LoadBalancer.Ingress {
+		if i.Hostname != "" {
+			addrs = append(addrs, i.Hostname)
+		}
+		if i.IP != "" {
+			addrs = append(addrs, i.IP)
+		}
+	}
+	if len(addrs) == 0 {
+		return fmt.Sprintf("Waiting for ingress %q to be assigned a load balancer address...\n", name), false, nil
+	}
+	return fmt.Sprintf("ingress %q available at %s\n", name, strings.Join(addrs, ", ")), true, nil
+}
+
 // Status returns a message describing deployment status, and a bool value indicating if the status is considered done.
 func (s *DeploymentStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
 	deployment := &appsv1.Deployment{}
@@ -118,7 +286,14 @@ func (s *DeploymentStatusViewer) Status(name types.NamespacedName, revision int6
 		if cond != nil && cond.Reason == TimedOutReason {
 			return "", false, fmt.Errorf("deployment %q exceeded its progress deadline", name)
 		}
-		if deployment.Spec.Replicas != nil && deployment.Status.UpdatedReplicas < *deployment.Spec.Replicas {
+		// A crash-looping new ReplicaSet can sit under the progress deadline for minutes -
+		// fail fast on pod-level failures instead of waiting the deadline out
+		if err := s.podFailure(deployment); err != nil {
+			return "", false, err
+		}
+		// Don't wait for spec.replicas when an autoscaler owns scaling - the replica count is
+		// driven by the autoscaler rather than the applied config
+		if !s.hpaOwnsScaling(deployment) && deployment.Spec.Replicas != nil && deployment.Status.UpdatedReplicas < *deployment.Spec.Replicas {
 			return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated...\n", name, deployment.Status.UpdatedReplicas, *deployment.Spec.Replicas), false, nil
 		}
 		if deployment.Status.Replicas > deployment.Status.UpdatedReplicas {
@@ -132,6 +307,99 @@ func (s *DeploymentStatusViewer) Status(name types.NamespacedName, revision int6
 	return fmt.Sprintf("Waiting for deployment spec update to be observed...\n"), false, nil
 }
 
+// crashLoopRestarts is the restart count at which a CrashLoopBackOff container is considered
+// a failure rather than a transient hiccup
+const crashLoopRestarts = 2
+
+// fatalWaitingReasons are container waiting reasons that never resolve without a new image
+// or config, so there is no point waiting the progress deadline out
+var fatalWaitingReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+}
+
+// podFailure returns an error naming the offending container and message if any of the
+// deployment's pods are crash-looping or stuck on an unrecoverable waiting reason
+func (s *DeploymentStatusViewer) podFailure(deployment *appsv1.Deployment) error {
+	if deployment.Spec.Selector == nil || len(deployment.Spec.Selector.MatchLabels) == 0 {
+		return nil
+	}
+
+	// Only the new ReplicaSet's pods are checked - old pods crash-looping on the previous
+	// version must not abort the rollout that replaces them
+	selector := map[string]string{}
+	for k, v := range deployment.Spec.Selector.MatchLabels {
+		selector[k] = v
+	}
+	hash := s.newReplicaSetHash(deployment)
+	if hash == "" {
+		// Without the hash the selector would also match the old ReplicaSet's pods -
+		// skip the fast-fail check and let the progress deadline decide
+		return nil
+	}
+	selector["pod-template-hash"] = hash
+
+	pods := &corev1.PodList{}
+	opts := client.InNamespace(deployment.Namespace).MatchingLabels(selector)
+	if err := s.List(context.TODO(), opts, pods); err != nil {
+		return nil
+	}
+	for _, pod := range pods.Items {
+		for _, cs := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			w := cs.State.Waiting
+			if w == nil {
+				continue
+			}
+			if w.Reason == "CrashLoopBackOff" && cs.RestartCount >= crashLoopRestarts {
+				return fmt.Errorf("pod %q container %q is crash-looping (%d restarts): %s",
+					pod.Name, cs.Name, cs.RestartCount, w.Message)
+			}
+			if fatalWaitingReasons[w.Reason] {
+				return fmt.Errorf("pod %q container %q is stuck in %s: %s",
+					pod.Name, cs.Name, w.Reason, w.Message)
+			}
+		}
+	}
+	return nil
+}
+
+// newReplicaSetHash returns the pod-template-hash of the deployment's newest ReplicaSet,
+// matched by revision annotation.  Returns "" if the new ReplicaSet can not be identified.
+func (s *DeploymentStatusViewer) newReplicaSetHash(deployment *appsv1.Deployment) string {
+	rev, err := Revision(deployment)
+	if err != nil || rev == 0 {
+		return ""
+	}
+	rss := &appsv1.ReplicaSetList{}
+	opts := client.InNamespace(deployment.Namespace).MatchingLabels(deployment.Spec.Selector.MatchLabels)
+	if err := s.List(context.TODO(), opts, rss); err != nil {
+		return ""
+	}
+	for i := range rss.Items {
+		if r, err := Revision(&rss.Items[i]); err == nil && r == rev {
+			return rss.Items[i].Labels["pod-template-hash"]
+		}
+	}
+	return ""
+}
+
+// hpaOwnsScaling returns true if a HorizontalPodAutoscaler targets the deployment
+func (s *DeploymentStatusViewer) hpaOwnsScaling(deployment *appsv1.Deployment) bool {
+	hpas := &autoscalingv1.HorizontalPodAutoscalerList{}
+	if err := s.List(context.TODO(), client.InNamespace(deployment.Namespace), hpas); err != nil {
+		return false
+	}
+	for _, hpa := range hpas.Items {
+		ref := hpa.Spec.ScaleTargetRef
+		if ref.Kind == "Deployment" && ref.Name == deployment.Name {
+			return true
+		}
+	}
+	return false
+}
+
 // Status returns a message describing daemon set status, and a bool value indicating if the status is considered done.
 func (s *DaemonSetStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
 	//ignoring revision as DaemonSets does not have history yet
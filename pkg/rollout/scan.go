@@ -0,0 +1,86 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"fmt"
+)
+
+// SecurityFinding is a configuration issue found by scanning a rendered object
+type SecurityFinding struct {
+	// Severity is one of critical or warning
+	Severity string
+
+	// Message describes the issue
+	Message string
+}
+
+// SecurityFindings runs configuration checks on the object's rendered config - privileged
+// containers, host namespaces, hostPath volumes, missing resource limits - and returns the
+// findings
+func (o *Object) SecurityFindings() []SecurityFinding {
+	var findings []SecurityFinding
+	scanConfig(o.parsed.Object, &findings)
+	return findings
+}
+
+// scanConfig recursively walks parsed config, collecting insecure configuration findings
+func scanConfig(v interface{}, findings *[]SecurityFinding) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if p, ok := t["privileged"].(bool); ok && p {
+			*findings = append(*findings, SecurityFinding{
+				Severity: "critical", Message: "privileged container"})
+		}
+		for _, k := range []string{"hostNetwork", "hostPID", "hostIPC"} {
+			if b, ok := t[k].(bool); ok && b {
+				*findings = append(*findings, SecurityFinding{
+					Severity: "critical", Message: fmt.Sprintf("%s is enabled", k)})
+			}
+		}
+		if _, found := t["hostPath"]; found {
+			*findings = append(*findings, SecurityFinding{
+				Severity: "warning", Message: fmt.Sprintf("hostPath volume %q", t["name"])})
+		}
+		if cs, ok := t["containers"].([]interface{}); ok {
+			for _, c := range cs {
+				if m, ok := c.(map[string]interface{}); ok && !hasLimits(m) {
+					*findings = append(*findings, SecurityFinding{
+						Severity: "warning",
+						Message:  fmt.Sprintf("container %q has no resource limits", m["name"])})
+				}
+			}
+		}
+		for _, val := range t {
+			scanConfig(val, findings)
+		}
+	case []interface{}:
+		for _, val := range t {
+			scanConfig(val, findings)
+		}
+	}
+}
+
+// hasLimits returns true if the container has resource limits set
+func hasLimits(container map[string]interface{}) bool {
+	resources, ok := container["resources"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	limits, ok := resources["limits"].(map[string]interface{})
+	return ok && len(limits) > 0
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScanConfig(t *testing.T) {
+	config := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"hostNetwork": true,
+			"volumes": []interface{}{
+				map[string]interface{}{
+					"name":     "host",
+					"hostPath": map[string]interface{}{"path": "/var/run"},
+				},
+			},
+			"containers": []interface{}{
+				map[string]interface{}{
+					"name": "app",
+					"securityContext": map[string]interface{}{
+						"privileged": true,
+					},
+				},
+				map[string]interface{}{
+					"name": "sidecar",
+					"resources": map[string]interface{}{
+						"limits": map[string]interface{}{"cpu": "1"},
+					},
+				},
+			},
+		},
+	}
+
+	var findings []SecurityFinding
+	scanConfig(config, &findings)
+
+	expected := map[string]string{
+		"privileged container":                   "critical",
+		"hostNetwork is enabled":                 "critical",
+		`hostPath volume "host"`:                 "warning",
+		`container "app" has no resource limits`: "warning",
+	}
+	for message, severity := range expected {
+		found := false
+		for _, f := range findings {
+			if f.Message == message && f.Severity == severity {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected a %s finding %q in %v", severity, message, findings)
+		}
+	}
+	for _, f := range findings {
+		if strings.Contains(f.Message, "sidecar") {
+			t.Errorf("expected no findings for the container with limits, got %q", f.Message)
+		}
+	}
+}
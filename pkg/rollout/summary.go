@@ -0,0 +1,81 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"time"
+)
+
+// Summary is a machine-readable record of a rollout for consumption by downstream automation
+type Summary struct {
+	Name     string          `json:"name"`
+	Commit   string          `json:"commit"`
+	Status   string          `json:"status"`
+	Started  time.Time       `json:"started"`
+	Finished time.Time       `json:"finished"`
+	Targets  []TargetSummary `json:"targets"`
+}
+
+// TargetSummary records the outcome of a single target
+type TargetSummary struct {
+	Path    string          `json:"path"`
+	Status  string          `json:"status"`
+	Objects []ObjectSummary `json:"objects"`
+}
+
+// ObjectSummary records the outcome of a single object
+type ObjectSummary struct {
+	Object          string    `json:"object"`
+	Hook            string    `json:"hook,omitempty"`
+	ApplyStatus     string    `json:"applyStatus"`
+	RolloutStatus   string    `json:"rolloutStatus"`
+	Done            bool      `json:"done"`
+	Started         time.Time `json:"started,omitempty"`
+	Finished        time.Time `json:"finished,omitempty"`
+	DurationSeconds float64   `json:"durationSeconds"`
+}
+
+// Summarize builds a Summary of the rollouts for a commit
+func Summarize(ros *Rollouts, commit string, started, finished time.Time) *Summary {
+	s := &Summary{
+		Name:     ros.Name,
+		Commit:   commit,
+		Status:   ros.Status,
+		Started:  started,
+		Finished: finished,
+	}
+	for _, ro := range ros.Rollouts {
+		t := TargetSummary{Path: ro.Path, Status: ro.Status}
+		for _, o := range append(ro.Hooks, ro.Objects...) {
+			os := ObjectSummary{
+				Object:        o.Display(),
+				Hook:          o.Hook,
+				ApplyStatus:   o.ApplyStatus,
+				RolloutStatus: o.RolloutStatus,
+				Done:          o.Done,
+				Started:       o.Started,
+				Finished:      o.Finished,
+			}
+			if !o.Started.IsZero() && !o.Finished.IsZero() {
+				os.DurationSeconds = o.Finished.Sub(o.Started).Seconds()
+			}
+			t.Objects = append(t.Objects, os)
+		}
+		s.Targets = append(s.Targets, t)
+	}
+	return s
+}
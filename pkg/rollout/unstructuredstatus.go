@@ -0,0 +1,384 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getUnstructuredViewer returns a StatusViewer for extension types not registered in the scheme,
+// dispatching on the group and kind.  Returns nil if the type has no viewer.
+func getUnstructuredViewer(gvk schema.GroupVersionKind, c client.Client) StatusViewer {
+	switch {
+	case gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition":
+		return &CRDStatusViewer{Client: c, GVK: gvk}
+	case gvk.Group == "apiregistration.k8s.io" && gvk.Kind == "APIService":
+		return &APIServiceStatusViewer{Client: c, GVK: gvk}
+	case gvk.Group == "admissionregistration.k8s.io" &&
+		(gvk.Kind == "ValidatingWebhookConfiguration" || gvk.Kind == "MutatingWebhookConfiguration"):
+		return &WebhookConfigStatusViewer{Client: c, GVK: gvk}
+	case gvk.Group == "bitnami.com" && gvk.Kind == "SealedSecret":
+		return &SealedSecretStatusViewer{Client: c}
+	case gvk.Group == "external-secrets.io" && gvk.Kind == "ExternalSecret":
+		return &ExternalSecretStatusViewer{Client: c, GVK: gvk}
+	case (gvk.Group == "cert-manager.io" || gvk.Group == "certmanager.k8s.io") && gvk.Kind == "Certificate":
+		return &CertificateStatusViewer{Client: c, GVK: gvk}
+	case gvk.Group == "argoproj.io" && gvk.Kind == "Rollout":
+		return &ArgoRolloutStatusViewer{Client: c, GVK: gvk}
+	case gvk.Group == "flagger.app" && gvk.Kind == "Canary":
+		return &CanaryStatusViewer{Client: c, GVK: gvk}
+	case gvk.Group == "serving.knative.dev" && gvk.Kind == "Service":
+		return &KnativeServiceStatusViewer{Client: c, GVK: gvk}
+	}
+
+	// Fall back to an operator-registered exec plugin for kinds without a built-in viewer
+	if command, found := statusPlugin(gvk); found {
+		return &ExecStatusViewer{Client: c, GVK: gvk, Command: command}
+	}
+
+	return nil
+}
+
+// CRDStatusViewer implements the StatusViewer interface for CustomResourceDefinitions.
+type CRDStatusViewer struct {
+	client.Client
+	GVK schema.GroupVersionKind
+}
+
+// Status returns a message describing CRD status, and a bool value indicating if the
+// status is considered done.  A CRD is done once its NamesAccepted and Established
+// conditions are True, so dependent CRs in later waves don't race the apiserver.
+func (s *CRDStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(s.GVK)
+	if err := s.Get(context.TODO(), name, u); err != nil {
+		return "", false, err
+	}
+
+	accepted, message, found := unstructuredCondition(u, "NamesAccepted")
+	if found && accepted == string(corev1.ConditionFalse) {
+		return "", false, fmt.Errorf("crd %q names not accepted: %s", name, message)
+	}
+
+	established, message, found := unstructuredCondition(u, "Established")
+	if !found {
+		return fmt.Sprintf("Waiting for crd %q to report status...\n", name), false, nil
+	}
+	if established != string(corev1.ConditionTrue) {
+		return fmt.Sprintf("Waiting for crd %q to be established: %s\n", name, message), false, nil
+	}
+
+	// A Webhook conversion strategy without a client config would fail every dependent
+	// CR write, so surface it before later waves hit it
+	strategy, _, _ := unstructured.NestedString(u.Object, "spec", "conversion", "strategy")
+	if strategy == "Webhook" {
+		config, _, _ := unstructured.NestedMap(u.Object, "spec", "conversion", "webhookClientConfig")
+		if len(config) == 0 {
+			return "", false, fmt.Errorf("crd %q uses Webhook conversion without a webhookClientConfig", name)
+		}
+	}
+	return fmt.Sprintf("crd %q established\n", name), true, nil
+}
+
+// APIServiceStatusViewer implements the StatusViewer interface for apiregistration
+// APIServices.
+type APIServiceStatusViewer struct {
+	client.Client
+	GVK schema.GroupVersionKind
+}
+
+// Status returns a message describing APIService status, and a bool value indicating if
+// the status is considered done.  An APIService is done once its Available condition is
+// True, so rollouts installing aggregated APIs - e.g. metrics-server - wait until they
+// actually serve.  Locally-served groups have no backing service and are done immediately.
+func (s *APIServiceStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(s.GVK)
+	if err := s.Get(context.TODO(), name, u); err != nil {
+		return "", false, err
+	}
+
+	if _, found, _ := unstructured.NestedMap(u.Object, "spec", "service"); !found {
+		return fmt.Sprintf("apiservice %q served locally\n", name), true, nil
+	}
+
+	status, message, found := unstructuredCondition(u, "Available")
+	if !found {
+		return fmt.Sprintf("Waiting for apiservice %q to report status...\n", name), false, nil
+	}
+	if status != string(corev1.ConditionTrue) {
+		return fmt.Sprintf("Waiting for apiservice %q to become available: %s\n", name, message), false, nil
+	}
+	return fmt.Sprintf("apiservice %q available\n", name), true, nil
+}
+
+// WebhookConfigStatusViewer implements the StatusViewer interface for admission webhook
+// configurations.
+type WebhookConfigStatusViewer struct {
+	client.Client
+	GVK schema.GroupVersionKind
+}
+
+// Status returns a message describing webhook configuration status, and a bool value
+// indicating if the status is considered done.  A webhook configuration is done once the
+// Service backing each webhook has ready endpoints, so objects the webhook admits aren't
+// applied during a window of cluster-wide admission failures.  Webhooks addressed by URL
+// point outside the cluster and are not checked.
+func (s *WebhookConfigStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(s.GVK)
+	if err := s.Get(context.TODO(), name, u); err != nil {
+		return "", false, err
+	}
+
+	webhooks, _, _ := unstructured.NestedSlice(u.Object, "webhooks")
+	for _, w := range webhooks {
+		hook, ok := w.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		service, found, _ := unstructured.NestedMap(hook, "clientConfig", "service")
+		if !found {
+			continue
+		}
+		backend := types.NamespacedName{
+			Namespace: fmt.Sprintf("%v", service["namespace"]),
+			Name:      fmt.Sprintf("%v", service["name"]),
+		}
+
+		endpoints := &corev1.Endpoints{}
+		err := s.Get(context.TODO(), backend, endpoints)
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("Waiting for webhook %q service %q to exist...\n", name, backend), false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if !endpointsReady(endpoints) {
+			return fmt.Sprintf("Waiting for webhook %q service %q to have ready endpoints...\n",
+				name, backend), false, nil
+		}
+	}
+	return fmt.Sprintf("webhook %q serving\n", name), true, nil
+}
+
+// endpointsReady returns true if any endpoint address is ready to serve
+func endpointsReady(e *corev1.Endpoints) bool {
+	for _, s := range e.Subsets {
+		if len(s.Addresses) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SealedSecretStatusViewer implements the StatusViewer interface for bitnami.com SealedSecrets.
+type SealedSecretStatusViewer struct {
+	client.Client
+}
+
+// ExternalSecretStatusViewer implements the StatusViewer interface for external-secrets.io
+// ExternalSecrets.
+type ExternalSecretStatusViewer struct {
+	client.Client
+	GVK schema.GroupVersionKind
+}
+
+// Status returns a message describing sealed secret status, and a bool value indicating if the
+// status is considered done.  A sealed secret is done once the unsealed Secret exists.
+func (s *SealedSecretStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	secret := &corev1.Secret{}
+	err := s.Get(context.TODO(), name, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("Waiting for sealed secret %q to be unsealed...\n", name), false, nil
+		}
+		return "", false, err
+	}
+	return fmt.Sprintf("sealed secret %q unsealed\n", name), true, nil
+}
+
+// Status returns a message describing external secret status, and a bool value indicating if the
+// status is considered done.  An external secret is done once its SecretSynced condition is True.
+func (s *ExternalSecretStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(s.GVK)
+	if err := s.Get(context.TODO(), name, u); err != nil {
+		return "", false, err
+	}
+
+	status, message, found := unstructuredCondition(u, "SecretSynced")
+	if !found {
+		// Fall back to the Ready condition used by newer controller versions
+		status, message, found = unstructuredCondition(u, "Ready")
+	}
+	if !found {
+		return fmt.Sprintf("Waiting for external secret %q to report status...\n", name), false, nil
+	}
+	if status != string(corev1.ConditionTrue) {
+		return fmt.Sprintf("Waiting for external secret %q to sync: %s\n", name, message), false, nil
+	}
+	return fmt.Sprintf("external secret %q synced\n", name), true, nil
+}
+
+// CertificateStatusViewer implements the StatusViewer interface for cert-manager Certificates.
+type CertificateStatusViewer struct {
+	client.Client
+	GVK schema.GroupVersionKind
+}
+
+// Status returns a message describing certificate status, and a bool value indicating if the
+// status is considered done.  A certificate is done once its Ready condition is True.
+func (s *CertificateStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(s.GVK)
+	if err := s.Get(context.TODO(), name, u); err != nil {
+		return "", false, err
+	}
+
+	status, message, found := unstructuredCondition(u, "Ready")
+	if !found {
+		return fmt.Sprintf("Waiting for certificate %q to report status...\n", name), false, nil
+	}
+	if status != string(corev1.ConditionTrue) {
+		return fmt.Sprintf("Waiting for certificate %q to be issued: %s\n", name, message), false, nil
+	}
+	return fmt.Sprintf("certificate %q issued\n", name), true, nil
+}
+
+// ArgoRolloutStatusViewer implements the StatusViewer interface for argoproj.io Rollouts.
+type ArgoRolloutStatusViewer struct {
+	client.Client
+	GVK schema.GroupVersionKind
+}
+
+// Status returns a message describing argo rollout status, and a bool value indicating if the
+// status is considered done.  A rollout is done once its phase is Healthy.
+func (s *ArgoRolloutStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(s.GVK)
+	if err := s.Get(context.TODO(), name, u); err != nil {
+		return "", false, err
+	}
+
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+	message, _, _ := unstructured.NestedString(u.Object, "status", "message")
+	switch phase {
+	case "Healthy":
+		return fmt.Sprintf("rollout %q healthy\n", name), true, nil
+	case "Degraded", "Aborted":
+		return "", false, fmt.Errorf("rollout %q %s: %s", name, strings.ToLower(phase), message)
+	case "":
+		return fmt.Sprintf("Waiting for rollout %q to report status...\n", name), false, nil
+	default:
+		return fmt.Sprintf("Waiting for rollout %q to finish: %s %s\n", name, phase, message), false, nil
+	}
+}
+
+// CanaryStatusViewer implements the StatusViewer interface for flagger.app Canaries.
+type CanaryStatusViewer struct {
+	client.Client
+	GVK schema.GroupVersionKind
+}
+
+// Status returns a message describing canary status, and a bool value indicating if the status
+// is considered done.  A canary is done once its phase is Initialized or Succeeded.
+func (s *CanaryStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(s.GVK)
+	if err := s.Get(context.TODO(), name, u); err != nil {
+		return "", false, err
+	}
+
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+	weight, _, _ := unstructured.NestedInt64(u.Object, "status", "canaryWeight")
+	switch phase {
+	case "Initialized", "Succeeded":
+		return fmt.Sprintf("canary %q %s\n", name, strings.ToLower(phase)), true, nil
+	case "Failed":
+		return "", false, fmt.Errorf("canary %q failed", name)
+	case "":
+		return fmt.Sprintf("Waiting for canary %q to report status...\n", name), false, nil
+	default:
+		return fmt.Sprintf("Waiting for canary %q to finish: %s at %d%% weight\n", name, phase, weight), false, nil
+	}
+}
+
+// KnativeServiceStatusViewer implements the StatusViewer interface for serving.knative.dev
+// Services.
+type KnativeServiceStatusViewer struct {
+	client.Client
+	GVK schema.GroupVersionKind
+}
+
+// Status returns a message describing knative service status, and a bool value indicating if the
+// status is considered done.  A service is done once its Ready condition is True and the latest
+// created revision is the latest ready revision.
+func (s *KnativeServiceStatusViewer) Status(name types.NamespacedName, revision int64) (string, bool, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(s.GVK)
+	if err := s.Get(context.TODO(), name, u); err != nil {
+		return "", false, err
+	}
+
+	status, message, found := unstructuredCondition(u, "Ready")
+	if !found {
+		return fmt.Sprintf("Waiting for knative service %q to report status...\n", name), false, nil
+	}
+	if status == string(corev1.ConditionFalse) {
+		return "", false, fmt.Errorf("knative service %q not ready: %s", name, message)
+	}
+	if status != string(corev1.ConditionTrue) {
+		return fmt.Sprintf("Waiting for knative service %q to become ready: %s\n", name, message), false, nil
+	}
+
+	created, _, _ := unstructured.NestedString(u.Object, "status", "latestCreatedRevisionName")
+	ready, _, _ := unstructured.NestedString(u.Object, "status", "latestReadyRevisionName")
+	if created != ready {
+		return fmt.Sprintf("Waiting for knative service %q revision %s to become ready...\n", name, created), false, nil
+	}
+	return fmt.Sprintf("knative service %q ready at revision %s\n", name, ready), true, nil
+}
+
+// unstructuredCondition returns the status and message of the status condition with the given
+// type, and whether the condition was found
+func unstructuredCondition(u *unstructured.Unstructured, condType string) (string, string, bool) {
+	conditions, found, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if err != nil || !found {
+		return "", "", false
+	}
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if fmt.Sprintf("%v", cond["type"]) != condType {
+			continue
+		}
+		return fmt.Sprintf("%v", cond["status"]), fmt.Sprintf("%v", cond["message"]), true
+	}
+	return "", "", false
+}